@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/gpdionisio/umcp_cryptography/week_04-cbc_mac"
+)
+
+const CBCMAC_CHALLENGE = "I, the server, hereby agree that I will pay $100 to this student" //nolint
+
+type cbcMacResult struct {
+	Message string `json:"message"`
+	Tag     string `json:"tag"`
+	Valid   bool   `json:"valid"`
+}
+
+func (r *cbcMacResult) printText(w io.Writer) {
+	fmt.Fprintf(w, "Tag: %s\n", r.Tag)
+	fmt.Fprintln(w, r.Valid)
+}
+
+func runCbcMacForge(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("cbcmac-forge", flag.ExitOnError)
+	var input inputFlags
+	input.register(fs, "raw")
+	host := fs.String("host", "127.0.0.1", "oracle host")
+	port := fs.String("port", "49102", "mac oracle port")
+	vrfyPort := fs.String("vrfy-port", "49103", "vrfy oracle port")
+	output := registerOutput(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of cbcmac-forge (the message "+
+			"defaults to the challenge, its length must be a multiple of 32, at most 224):\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	mess := []byte(CBCMAC_CHALLENGE)
+	if input.given() {
+		var err error
+		mess, err = input.bytes()
+		if err != nil {
+			return err
+		}
+	}
+
+	o, err := cbcmac.NewMacOracle(*host, *port, *vrfyPort)
+	if err != nil {
+		return err
+	}
+	defer o.Disconnect()
+
+	tag, err := o.Forge(ctx, mess)
+	if err != nil {
+		return err
+	}
+	// check the tag against the full message
+	ok, err := o.Vrfy(ctx, mess, tag)
+	if err != nil {
+		return err
+	}
+	return emit(*output, &cbcMacResult{
+		Message: hex.EncodeToString(mess),
+		Tag:     hex.EncodeToString(tag),
+		Valid:   ok,
+	})
+}
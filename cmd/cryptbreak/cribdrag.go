@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gpdionisio/umcp_cryptography/week_02-many_time_pad"
+)
+
+const cribDragHelp = `Commands:
+  drag <text>                 offsets where text may be, printable in the other messages
+  lock <msg> <offset> <text>  set the key so that message msg holds text at offset
+  undo                        revert the last lock
+  show                        print the key and the plaintexts
+  save [file]                 save the session (to -session by default)
+  quit                        leave (also on end of input)
+The text is the rest of the line after a single space, spaces included.
+`
+
+func runCribDrag(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("cribdrag", flag.ExitOnError)
+	var input inputFlags
+	input.register(fs, "hex")
+	sessionFile := fs.String("session", "", "resume the session saved in this file, "+
+		"if it exists, and save it there")
+	fs.Parse(args)
+
+	s, err := openSession(&input, *sessionFile)
+	if err != nil {
+		return err
+	}
+	printSession(os.Stdout, s)
+	fmt.Print(cribDragHelp)
+
+	lines := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("> ")
+		if ctx.Err() != nil || !lines.Scan() {
+			fmt.Println()
+			return lines.Err()
+		}
+		cmd, arg, _ := strings.Cut(lines.Text(), " ")
+		switch strings.TrimSpace(cmd) {
+		case "":
+		case "drag":
+			if arg == "" {
+				fmt.Println("drag needs a text")
+				continue
+			}
+			printMatches(os.Stdout, manytimepad.DragCrib(s.Ciphertexts, []byte(arg)))
+		case "lock":
+			if err := lock(s, arg); err != nil {
+				fmt.Println(err)
+				continue
+			}
+			printSession(os.Stdout, s)
+		case "undo":
+			if !s.Undo() {
+				fmt.Println("nothing to undo")
+				continue
+			}
+			printSession(os.Stdout, s)
+		case "show":
+			printSession(os.Stdout, s)
+		case "save":
+			name := strings.TrimSpace(arg)
+			if name == "" {
+				name = *sessionFile
+			}
+			if err := saveSession(s, name); err != nil {
+				fmt.Println(err)
+			}
+		case "quit", "exit":
+			return nil
+		case "help":
+			fmt.Print(cribDragHelp)
+		default:
+			fmt.Printf("unknown command %q\n%s", cmd, cribDragHelp)
+		}
+	}
+}
+
+// openSession resumes the session file if it exists, otherwise starts
+// a session from RecoverKey on the input ciphertexts
+func openSession(input *inputFlags, name string) (*manytimepad.Session, error) {
+	if name != "" {
+		f, err := os.Open(name)
+		if err == nil {
+			defer f.Close()
+			return manytimepad.LoadSession(f)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	// stdin holds the commands
+	if !input.given() {
+		return nil, errors.New("the ciphertexts must be given with -in or -file (or a -session to resume)")
+	}
+	ciphertexts, err := input.lines()
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertexts) < 2 {
+		return nil, errors.New("at least 2 ciphertexts are needed")
+	}
+	return manytimepad.NewSession(ciphertexts, manytimepad.RecoverKey(ciphertexts)), nil
+}
+
+func saveSession(s *manytimepad.Session, name string) error {
+	if name == "" {
+		return errors.New("save needs a file (no -session given)")
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := s.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// lock parses "<msg> <offset> <text>"
+func lock(s *manytimepad.Session, arg string) error {
+	msg, rest, _ := strings.Cut(arg, " ")
+	offset, text, ok := strings.Cut(rest, " ")
+	if !ok || text == "" {
+		return errors.New("usage: lock <msg> <offset> <text>")
+	}
+	m, err := strconv.Atoi(msg)
+	if err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(offset)
+	if err != nil {
+		return err
+	}
+	return s.Lock(m, n, []byte(text))
+}
+
+func printSession(w io.Writer, s *manytimepad.Session) {
+	fmt.Fprintf(w, "Key: %x\n", s.Key)
+	for i, pt := range s.Plaintexts() {
+		fmt.Fprintf(w, "%d) %s\n", i, printable(pt))
+	}
+}
+
+func printMatches(w io.Writer, matches []manytimepad.CribMatch) {
+	if len(matches) == 0 {
+		fmt.Fprintln(w, "no match")
+	}
+	for _, m := range matches {
+		fmt.Fprintf(w, "msg %d offset %d:", m.Msg, m.Offset)
+		for j, text := range m.Others {
+			if j != m.Msg {
+				fmt.Fprintf(w, " %d=%q", j, text)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// printable replaces the bytes that are not printable ASCII with '*'
+func printable(text []byte) string {
+	out := make([]byte, len(text))
+	for i, b := range text {
+		if b < 0x20 || b >= 0x7F {
+			b = '*'
+		}
+		out[i] = b
+	}
+	return string(out)
+}
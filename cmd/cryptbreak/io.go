@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// inputFlags selects where the input of a command comes from
+// (-in, -file or stdin) and how it is encoded
+type inputFlags struct {
+	in       string
+	file     string
+	encoding string
+}
+
+func (f *inputFlags) register(fs *flag.FlagSet, encoding string) {
+	fs.StringVar(&f.in, "in", "", "input value")
+	fs.StringVar(&f.file, "file", "", "read the input from this file")
+	fs.StringVar(&f.encoding, "encoding", encoding,
+		"input encoding: hex, base64 or raw")
+}
+
+// given reports whether the input was set with -in or -file
+func (f *inputFlags) given() bool {
+	return f.in != "" || f.file != ""
+}
+
+// read returns the undecoded input, from stdin if neither -in nor -file is set
+func (f *inputFlags) read() ([]byte, error) {
+	switch {
+	case f.in != "" && f.file != "":
+		return nil, fmt.Errorf("-in and -file are mutually exclusive")
+	case f.in != "":
+		return []byte(f.in), nil
+	case f.file != "":
+		return os.ReadFile(f.file)
+	default:
+		return io.ReadAll(os.Stdin)
+	}
+}
+
+// bytes returns the decoded input as a single value (whitespace is ignored
+// unless the encoding is raw)
+func (f *inputFlags) bytes() ([]byte, error) {
+	data, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	if f.encoding == "raw" {
+		return data, nil
+	}
+	return decode(strings.Join(strings.Fields(string(data)), ""), f.encoding)
+}
+
+// open returns a reader of the decoded input, to be closed
+func (f *inputFlags) open() (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	switch {
+	case f.in != "" && f.file != "":
+		return nil, fmt.Errorf("-in and -file are mutually exclusive")
+	case f.in != "":
+		rc = io.NopCloser(strings.NewReader(f.in))
+	case f.file != "":
+		file, err := os.Open(f.file)
+		if err != nil {
+			return nil, err
+		}
+		rc = file
+	default:
+		rc = io.NopCloser(os.Stdin)
+	}
+	switch f.encoding {
+	case "hex":
+		return readCloser{hex.NewDecoder(skipSpace{rc}), rc}, nil
+	case "base64":
+		return readCloser{base64.NewDecoder(base64.StdEncoding, skipSpace{rc}), rc}, nil
+	case "raw":
+		return rc, nil
+	default:
+		rc.Close()
+		return nil, fmt.Errorf("unknown encoding %q", f.encoding)
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// skipSpace drops the whitespace of the encoded input
+type skipSpace struct {
+	r io.Reader
+}
+
+func (s skipSpace) Read(p []byte) (int, error) {
+	for {
+		n, err := s.r.Read(p)
+		m := 0
+		for _, b := range p[:n] {
+			if b != ' ' && b != '\t' && b != '\n' && b != '\r' {
+				p[m] = b
+				m++
+			}
+		}
+		if m > 0 || err != nil {
+			return m, err
+		}
+	}
+}
+
+// lines returns the decoded input as one value per non-empty line
+func (f *inputFlags) lines() ([][]byte, error) {
+	data, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	var values [][]byte
+	for _, line := range strings.Split(string(data), "\n") {
+		if f.encoding != "raw" {
+			line = strings.TrimSpace(line)
+		}
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+		v, err := decode(line, f.encoding)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func decode(s, encoding string) ([]byte, error) {
+	switch encoding {
+	case "hex":
+		return hex.DecodeString(s)
+	case "base64":
+		return base64.StdEncoding.DecodeString(s)
+	case "raw":
+		return []byte(s), nil
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+}
+
+// result is the outcome of a command, printed as text or JSON
+type result interface {
+	printText(w io.Writer)
+}
+
+// outputFormat is the -o flag, checked when parsing so that a long
+// attack is not run for nothing
+type outputFormat string
+
+func (o *outputFormat) String() string {
+	return string(*o)
+}
+
+func (o *outputFormat) Set(s string) error {
+	if s != "text" && s != "json" {
+		return fmt.Errorf("unknown output format %q", s)
+	}
+	*o = outputFormat(s)
+	return nil
+}
+
+func registerOutput(fs *flag.FlagSet) *outputFormat {
+	o := outputFormat("text")
+	fs.Var(&o, "o", "output format: text or json")
+	return &o
+}
+
+func emit(format outputFormat, r result) error {
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	r.printText(os.Stdout)
+	return nil
+}
@@ -0,0 +1,71 @@
+// Command cryptbreak runs the attacks of every week from one binary.
+//
+// Usage:
+//
+//	cryptbreak <command> [flags]
+//
+// Run "cryptbreak <command> -h" for the flags of each command.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+	// the command gives up when ctx is done, so Ctrl-C cancels ctx
+	// instead of killing the process
+	cancellable bool
+}
+
+var commands = []command{
+	{"vigenere", "break a Vigenere-like cipher (XOR, mod 26 or autokey)", runVigenere, false},
+	{"vigenere-bench", "measure the success rate of the vigenere breaker", runVigenereBench, false},
+	{"manytimepad", "recover the plaintexts of a many-time pad", runManyTimePad, false},
+	{"cribdrag", "crib-drag a many-time pad interactively", runCribDrag, false},
+	{"twotimepad", "recover a reused keystream from binary templates", runTwoTimePad, false},
+	{"paddingoracle", "decrypt an AES-CBC ciphertext with a padding oracle", runPaddingOracle, true},
+	{"cbcmac-forge", "forge a basic CBC-MAC tag on a long message", runCbcMacForge, true},
+	{"rsa-forge", "forge a signature of J. Random Hacker's RSA scheme", runRsaForge, true},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: cryptbreak <command> [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-15s %s\n", c.name, c.summary)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		ctx, stop := context.Background(), func() {}
+		if c.cancellable {
+			ctx, stop = signal.NotifyContext(ctx, os.Interrupt)
+		}
+		err := c.run(ctx, os.Args[2:])
+		stop()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if name != "-h" && name != "-help" && name != "help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	}
+	usage()
+	os.Exit(2)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gpdionisio/umcp_cryptography/week_02-many_time_pad"
+)
+
+// fix is a manual adjustment "msg:pos:char", meaning that the plaintext
+// of ciphertext msg has char at position pos
+type fix struct {
+	msg, pos int
+	char     byte
+}
+
+type fixes []fix
+
+func (f *fixes) String() string {
+	return fmt.Sprint(*f)
+}
+
+func (f *fixes) Set(s string) error {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 || len(parts[2]) != 1 {
+		return fmt.Errorf("invalid fix %q (expected msg:pos:char)", s)
+	}
+	msg, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return err
+	}
+	pos, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return err
+	}
+	*f = append(*f, fix{msg: msg, pos: pos, char: parts[2][0]})
+	return nil
+}
+
+type manyTimePadResult struct {
+	Key        string    `json:"key"`
+	Coverage   []int     `json:"coverage"`
+	Confidence []float64 `json:"confidence"`
+	Unknown    []int     `json:"unknown,omitempty"`
+	Votes      [][]int   `json:"votes,omitempty"`
+	Plaintexts []string  `json:"plaintexts"`
+}
+
+func (r *manyTimePadResult) printText(w io.Writer) {
+	fmt.Fprintf(w, "Key: %s\n", r.Key)
+	fmt.Fprint(w, "Coverage:")
+	for _, c := range r.Coverage {
+		fmt.Fprintf(w, " %d", c)
+	}
+	fmt.Fprint(w, "\nConfidence:")
+	for _, c := range r.Confidence {
+		fmt.Fprintf(w, " %.2f", c)
+	}
+	fmt.Fprintln(w)
+	if len(r.Unknown) > 0 {
+		fmt.Fprint(w, "Unknown columns:")
+		for _, j := range r.Unknown {
+			fmt.Fprintf(w, " %d", j)
+		}
+		fmt.Fprintln(w)
+	}
+	if len(r.Votes) > 0 {
+		fmt.Fprintln(w, "Votes for a space (column: per ciphertext):")
+		for j, tally := range r.Votes {
+			fmt.Fprintf(w, "%3d:", j)
+			for _, n := range tally {
+				fmt.Fprintf(w, " %d", n)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	for i, pt := range r.Plaintexts {
+		fmt.Fprintf(w, "%d) %s\n", (i + 1), pt)
+	}
+}
+
+func runManyTimePad(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("manytimepad", flag.ExitOnError)
+	var input inputFlags
+	input.register(fs, "hex")
+	var manual fixes
+	fs.Var(&manual, "fix", "manual adjustment msg:pos:char (repeatable), "+
+		"e.g. 0:0:I if the first plaintext starts with 'I'")
+	heuristic := fs.Bool("heuristic", false, "only use the space heuristic, "+
+		"leaving the key bytes it cannot infer to the fixes")
+	votes := fs.Bool("votes", false, "print the votes for a space of every "+
+		"ciphertext at each column")
+	output := registerOutput(fs)
+	fs.Parse(args)
+
+	// ciphertexts, one per line
+	ciphertexts, err := input.lines()
+	if err != nil {
+		return err
+	}
+	if len(ciphertexts) < 3 {
+		return errors.New("at least 3 ciphertexts are needed")
+	}
+
+	tally := manytimepad.CountVotes(ciphertexts)
+	var key []byte
+	var known []bool
+	if *heuristic {
+		key, known = tally.Key(ciphertexts)
+	} else {
+		key = manytimepad.Solve(ciphertexts, nil, nil)
+	}
+	for _, f := range manual {
+		if f.msg < 0 || f.msg >= len(ciphertexts) ||
+			f.pos < 0 || f.pos >= len(key) || f.pos >= len(ciphertexts[f.msg]) {
+			return fmt.Errorf("fix %d:%d out of range", f.msg, f.pos)
+		}
+		manytimepad.FixKey(key, ciphertexts[f.msg], f.pos, f.char)
+		if known != nil {
+			known[f.pos] = true
+		}
+	}
+
+	r := &manyTimePadResult{
+		Key:        hex.EncodeToString(key),
+		Coverage:   manytimepad.Coverage(ciphertexts),
+		Confidence: manytimepad.Confidence(ciphertexts, key, nil, nil),
+	}
+	for j, ok := range known {
+		if !ok {
+			r.Unknown = append(r.Unknown, j)
+		}
+	}
+	if *votes {
+		r.Votes = tally.Tally
+	}
+	for _, pt := range manytimepad.Decrypt(key, ciphertexts) {
+		r.Plaintexts = append(r.Plaintexts, string(pt))
+	}
+	return emit(*output, r)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gpdionisio/umcp_cryptography/week_03-padding_oracle"
+)
+
+type paddingOracleResult struct {
+	Plaintext    string `json:"plaintext"`
+	PlaintextHex string `json:"plaintext_hex"`
+	Queries      int    `json:"queries"`
+	BlockQueries []int  `json:"block_queries"`
+}
+
+func (r *paddingOracleResult) printText(w io.Writer) {
+	fmt.Fprintf(w, "Result: %s\n", r.Plaintext)
+	fmt.Fprintf(w, "Queries: %d (per block: %s)\n", r.Queries, strings.Trim(fmt.Sprint(r.BlockQueries), "[]"))
+}
+
+type paddingOracleEncryptResult struct {
+	Ciphertext string `json:"ciphertext"`
+}
+
+func (r *paddingOracleEncryptResult) printText(w io.Writer) {
+	fmt.Fprintf(w, "Ciphertext: %s\n", r.Ciphertext)
+}
+
+func runPaddingOracle(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("paddingoracle", flag.ExitOnError)
+	var input inputFlags
+	input.register(fs, "hex")
+	host := fs.String("host", "127.0.0.1", "padding oracle host")
+	port := fs.String("port", "49101", "padding oracle port")
+	quiet := fs.Bool("q", false, "do not print the progress of the attack")
+	allBytes := fs.Bool("all-bytes", false, "try all the 256 values of every "+
+		"byte, for binary plaintexts")
+	workers := fs.Int("workers", 1, "number of blocks decrypted concurrently, "+
+		"each worker with its own connection to the server")
+	orderName := fs.String("order", "numeric", "order of the guesses of each "+
+		"byte: numeric, freq (English frequencies), ngram (English trigrams "+
+		"given the bytes after it) or context (the bytes that preceded the "+
+		"byte after it in the plaintext recovered so far)")
+	encrypt := fs.Bool("encrypt", false, "forge a ciphertext of the input "+
+		"instead (e.g. with -encoding raw), one block at a time with all "+
+		"the 256 values of every byte in -order")
+	output := registerOutput(fs)
+	fs.Parse(args)
+
+	// IV first (the plaintext with -encrypt)
+	ct, err := input.bytes()
+	if err != nil {
+		return err
+	}
+	order, err := paddingoracle.ParseGuessOrder(*orderName)
+	if err != nil {
+		return err
+	}
+	if *encrypt {
+		// each block is forged from the one after it
+		if *workers > 1 {
+			return errors.New("-workers does not apply to -encrypt, " +
+				"whose blocks are forged one after the other")
+		}
+		return forge(ctx, ct, *host, *port, order, *quiet, *output)
+	}
+
+	// the options of the attack of each oracle
+	configure := func(po *paddingoracle.PaddingOracle) {
+		po.AllGuesses = *allBytes
+		po.Order = order
+	}
+	var pt []byte
+	var blockQueries []int
+	if *workers > 1 {
+		pt, blockQueries, err = decryptPool(ctx, ct, *host, *port, *workers, configure, *quiet)
+	} else {
+		pt, blockQueries, err = decrypt(ctx, ct, *host, *port, configure, *quiet)
+	}
+	if err != nil {
+		return err
+	}
+	r := &paddingOracleResult{
+		Plaintext:    string(pt),
+		PlaintextHex: hex.EncodeToString(pt),
+		BlockQueries: blockQueries,
+	}
+	for _, n := range blockQueries {
+		r.Queries += n
+	}
+	return emit(*output, r)
+}
+
+func decrypt(ctx context.Context, ct []byte, host, port string,
+	configure func(*paddingoracle.PaddingOracle), quiet bool) ([]byte, []int, error) {
+	po, err := paddingoracle.NewPaddingOracle(host, port)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer po.Disconnect()
+	configure(po)
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "Connected to server at %s:%s\n", host, port)
+		po.Log = os.Stderr
+	}
+	pt, err := po.Decrypt(ctx, ct)
+	return pt, po.BlockQueries, err
+}
+
+func forge(ctx context.Context, pt []byte, host, port string,
+	order paddingoracle.GuessOrder, quiet bool, output outputFormat) error {
+	po, err := paddingoracle.NewPaddingOracle(host, port)
+	if err != nil {
+		return err
+	}
+	defer po.Disconnect()
+	po.Order = order
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "Connected to server at %s:%s\n", host, port)
+		po.Log = os.Stderr
+	}
+	ct, err := po.Encrypt(ctx, pt)
+	if err != nil {
+		return err
+	}
+	return emit(output, &paddingOracleEncryptResult{Ciphertext: hex.EncodeToString(ct)})
+}
+
+func decryptPool(ctx context.Context, ct []byte, host, port string, workers int,
+	configure func(*paddingoracle.PaddingOracle), quiet bool) ([]byte, []int, error) {
+	pool, err := paddingoracle.DialPool(host, port, workers)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer pool.Disconnect()
+	for _, w := range pool.Workers {
+		configure(w)
+	}
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "Connected %d workers to server at %s:%s\n", workers, host, port)
+		pool.Progress = func(done, total int) {
+			fmt.Fprintf(os.Stderr, "Decrypted %d/%d blocks\n", done, total)
+		}
+	}
+	pt, err := pool.Decrypt(ctx, ct)
+	return pt, pool.BlockQueries, err
+}
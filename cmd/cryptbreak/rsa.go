@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/gpdionisio/umcp_cryptography/week_07-rsa"
+	"github.com/gpdionisio/umcp_cryptography/week_07-rsa/server"
+)
+
+const (
+	// Address of the course server
+	RSA_COURSE_HOST = "128.8.130.16" //nolint
+
+	// Public key of the course server
+	RSA_N_HEX = "a99263f5cd9a6c3d93411fbf682859a07b5e41c38abade2a551798e6c8af5af0" + //nolint
+		"8dee5c7420c99f0f3372e8f2bfc4d0c85115b45a0abc540349bf08b251a80b85" +
+		"975214248dffe57095248d1c7e375125c1da25227926c99a5ba4432dfcfdae3" +
+		"00b795f1764af043e7c1a8e070f5229a4cbc6c5680ff2cd6fa1d62d39faf3d41d"
+	RSA_E_HEX = "10001" //nolint
+)
+
+type rsaResult struct {
+	Message   string `json:"message"`
+	Signature string `json:"signature"`
+	Valid     bool   `json:"valid"`
+}
+
+func (r *rsaResult) printText(w io.Writer) {
+	fmt.Fprintf(w, "Signature: %s\n", r.Signature)
+	fmt.Fprintln(w, r.Valid)
+}
+
+func runRsaForge(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("rsa-forge", flag.ExitOnError)
+	var input inputFlags
+	input.register(fs, "raw")
+	host := fs.String("host", "127.0.0.1", "oracle host")
+	port := fs.String("port", "49104", "sign oracle port")
+	vrfyPort := fs.String("vrfy-port", "49105", "vrfy oracle port")
+	nHex := fs.String("n", "", "public modulus (hex, required unless -host "+
+		"is the course server "+RSA_COURSE_HOST+")")
+	eHex := fs.String("e", RSA_E_HEX, "public exponent (hex)")
+	output := registerOutput(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of rsa-forge (the message "+
+			"defaults to the challenge, it must be even and at most 63 bytes):\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *nHex == "" {
+		if *host != RSA_COURSE_HOST {
+			return fmt.Errorf("-n is required with a local server " +
+				"(rsa-server prints its modulus)")
+		}
+		*nHex = RSA_N_HEX
+	}
+	N, ok := new(big.Int).SetString(*nHex, 16)
+	if !ok {
+		return fmt.Errorf("invalid modulus %q", *nHex)
+	}
+	e, ok := new(big.Int).SetString(*eHex, 16)
+	if !ok {
+		return fmt.Errorf("invalid exponent %q", *eHex)
+	}
+	mess := []byte(server.CHALLENGE)
+	if input.given() {
+		var err error
+		mess, err = input.bytes()
+		if err != nil {
+			return err
+		}
+	}
+	chall := new(big.Int).SetBytes(mess)
+
+	o, err := plainrsa.NewRsaOracle(*host, *port, *vrfyPort)
+	if err != nil {
+		return err
+	}
+	defer o.Disconnect()
+
+	// only the challenge is refused by the oracle
+	refused := chall
+	if !bytes.Equal(mess, []byte(server.CHALLENGE)) {
+		refused = nil
+	}
+	if err := o.Check(ctx, refused); err != nil {
+		return err
+	}
+	sig, err := o.Forge(ctx, N, e, chall)
+	if err != nil {
+		return err
+	}
+	ok, err = o.VrfyContext(ctx, chall, sig)
+	if err != nil {
+		return err
+	}
+	return emit(*output, &rsaResult{
+		Message:   hex.EncodeToString(mess),
+		Signature: sig.Text(16),
+		Valid:     ok,
+	})
+}
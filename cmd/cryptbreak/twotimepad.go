@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gpdionisio/umcp_cryptography/week_02-many_time_pad"
+)
+
+type templates []manytimepad.Template
+
+func (t *templates) String() string {
+	return fmt.Sprint(len(*t), " templates")
+}
+
+func (t *templates) Set(s string) error {
+	tmpl, err := manytimepad.ParseTemplate(s)
+	if err != nil {
+		return err
+	}
+	*t = append(*t, tmpl)
+	return nil
+}
+
+type twoTimePadResult struct {
+	Keystream  string   `json:"keystream"` // unknown bytes are 00
+	Known      []bool   `json:"known"`
+	Plaintexts []string `json:"plaintexts"` // hex, unknown bytes are 00
+	known      int
+	masked     []string
+}
+
+func (r *twoTimePadResult) printText(w io.Writer) {
+	fmt.Fprintf(w, "Known: %d of %d keystream bytes\n", r.known, len(r.Known))
+	fmt.Fprintf(w, "Keystream: %s\n", r.masked[0])
+	for i, pt := range r.masked[1:] {
+		fmt.Fprintf(w, "%d) %s\n", i, pt)
+	}
+}
+
+// mask writes the bytes as hex, "??" where unknown, followed by their
+// printable characters ('.' otherwise)
+func mask(data []byte, known []bool) string {
+	var h, text strings.Builder
+	for j, b := range data {
+		switch {
+		case !known[j]:
+			h.WriteString("??")
+			text.WriteByte(' ')
+		default:
+			fmt.Fprintf(&h, "%02x", b)
+			if b >= 0x20 && b < 0x7F {
+				text.WriteByte(b)
+			} else {
+				text.WriteByte('.')
+			}
+		}
+	}
+	return h.String() + "  " + text.String()
+}
+
+func runTwoTimePad(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("twotimepad", flag.ExitOnError)
+	var input inputFlags
+	input.register(fs, "hex")
+	var tmpls templates
+	fs.Var(&tmpls, "template", "known plaintext msg:offset:text (repeatable), msg and offset "+
+		"being '*' for any, text with Go escapes, e.g. '*:0:GET / HTTP/1.1\\r\\n' or '*:*:\"user\":'")
+	output := registerOutput(fs)
+	fs.Parse(args)
+
+	// ciphertexts, one per line
+	ciphertexts, err := input.lines()
+	if err != nil {
+		return err
+	}
+	if len(ciphertexts) < 2 {
+		return errors.New("at least 2 ciphertexts are needed")
+	}
+	if len(tmpls) == 0 {
+		return errors.New("no -template given")
+	}
+
+	key, known := manytimepad.ApplyTemplates(ciphertexts, tmpls)
+	r := &twoTimePadResult{
+		Keystream: hex.EncodeToString(key),
+		Known:     known,
+		masked:    []string{mask(key, known)},
+	}
+	for _, ok := range known {
+		if ok {
+			r.known++
+		}
+	}
+	for _, pt := range manytimepad.Decrypt(key, ciphertexts) {
+		clean := make([]byte, len(pt))
+		for j, b := range pt {
+			if known[j] {
+				clean[j] = b
+			}
+		}
+		r.Plaintexts = append(r.Plaintexts, hex.EncodeToString(clean))
+		r.masked = append(r.masked, mask(pt, known))
+	}
+	return emit(*output, r)
+}
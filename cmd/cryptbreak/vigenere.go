@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gpdionisio/umcp_cryptography/langmodel"
+	"github.com/gpdionisio/umcp_cryptography/week_01-vigenere"
+)
+
+type keyLenScore struct {
+	KeyLen int     `json:"key_len"`
+	Score  float64 `json:"score"`
+}
+
+type vigenereCandidate struct {
+	KeyLen    int     `json:"key_len"`
+	Score     float64 `json:"score"`
+	Key       string  `json:"key"`
+	KeyText   string  `json:"key_text,omitempty"`  // letter keys only
+	Missing   []int   `json:"missing,omitempty"`   // key bytes not found
+	Plaintext string  `json:"plaintext,omitempty"` // not with -stream
+
+	// the best alternatives for each key byte
+	Alternatives [][]keyByteScore `json:"alternatives,omitempty"`
+
+	// with -refine
+	Confidence []float64 `json:"confidence,omitempty"`
+	Corrected  []int     `json:"corrected,omitempty"` // key bytes changed by the refinement
+}
+
+type keyByteScore struct {
+	Key   string  `json:"key"`
+	Score float64 `json:"score"`
+}
+
+type vigenereResult struct {
+	vigenereCandidate                     // the best candidate
+	KeyLens           []keyLenScore       `json:"key_lens"`
+	Candidates        []vigenereCandidate `json:"candidates,omitempty"`
+}
+
+func (r *vigenereResult) printText(w io.Writer) {
+	if len(r.Candidates) > 0 {
+		fmt.Fprintln(w, "Key len ranking:")
+		for _, s := range r.KeyLens {
+			fmt.Fprintf(w, "  %3d  %.6f\n", s.KeyLen, s.Score)
+		}
+	}
+	fmt.Fprintf(w, "Candidate key len is %d\n", r.KeyLen)
+	for _, i := range r.Missing {
+		fmt.Fprintf(w, "Key byte at index %d ---> NOT FOUND!\n", i)
+	}
+	if r.KeyText != "" {
+		fmt.Fprintf(w, "Key: %s (%s)\n", r.KeyText, r.Key)
+	} else {
+		fmt.Fprintf(w, "Key: %s\n", r.Key)
+	}
+	if len(r.Confidence) > 0 {
+		fmt.Fprint(w, "Confidence:")
+		for _, c := range r.Confidence {
+			fmt.Fprintf(w, " %.2f", c)
+		}
+		fmt.Fprintln(w)
+	}
+	for _, i := range r.Corrected {
+		fmt.Fprintf(w, "Key byte at index %d corrected by the refinement\n", i)
+	}
+	for i, alts := range r.Alternatives {
+		fmt.Fprintf(w, "  key[%d]:", i)
+		for _, a := range alts {
+			fmt.Fprintf(w, " %s (%.4g)", a.Key, a.Score)
+		}
+		fmt.Fprintln(w)
+	}
+	if r.Plaintext != "" {
+		fmt.Fprintln(w, r.Plaintext)
+	}
+}
+
+func runVigenere(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("vigenere", flag.ExitOnError)
+	var input inputFlags
+	input.register(fs, "hex")
+	var opts vigenere.Options
+	fs.IntVar(&opts.MinKeyLen, "min-key-len", 1, "shortest key length searched")
+	fs.IntVar(&opts.MaxKeyLen, "max-key-len", vigenere.MAX_KEY_LEN,
+		"longest key length searched")
+	fs.IntVar(&opts.TopN, "top", 1, "number of best ranked key lengths tried")
+	cipher := fs.String("cipher", "xor", "cipher: xor, vigenere, beaufort, "+
+		"variant-beaufort or autokey")
+	estimator := fs.String("keylen", "", "key length estimator: sos, ioc, "+
+		"kasiski, hamming, decrypt or vote (sos for xor, decrypt for the others "+
+		"if empty)")
+	scoring := fs.String("scoring", "chi2", "key byte scoring: chi2, dot or charset")
+	freqFile := fs.String("freq-file", "", "sample plaintext for the unigram "+
+		"frequencies (English if empty)")
+	alternatives := fs.Int("alternatives", 0, "print the N best candidates "+
+		"of each key byte")
+	fs.BoolVar(&opts.Refine, "refine", false, "refine the key with hill "+
+		"climbing on an n-gram model")
+	ngramTable := fs.String("ngram-table", "", "n-gram table for -refine "+
+		"(the embedded English trigrams if empty)")
+	stream := fs.Bool("stream", false, "analyze the input as a stream, "+
+		"without loading it (needs -file to be read again for decryption)")
+	out := fs.String("out", "", "with -stream, write the plaintext of the "+
+		"best candidate to this file")
+	output := registerOutput(fs)
+	fs.Parse(args)
+
+	var err error
+	opts.Cipher, err = vigenere.ParseCipher(*cipher)
+	if err != nil {
+		return err
+	}
+	if *estimator != "" {
+		opts.Estimator, err = vigenere.ParseEstimator(*estimator)
+		if err != nil {
+			return err
+		}
+	}
+	opts.Scoring, err = vigenere.ParseScoring(*scoring)
+	if err != nil {
+		return err
+	}
+	if *ngramTable != "" {
+		opts.Model, err = readNgramTable(*ngramTable)
+		if err != nil {
+			return err
+		}
+	}
+	if *freqFile != "" {
+		sample, err := os.ReadFile(*freqFile)
+		if err != nil {
+			return err
+		}
+		opts.Frequencies = langmodel.NewUnigrams(sample)
+	}
+
+	var ranking []vigenere.KeyLenScore
+	var candidates []vigenere.Candidate
+	if *stream {
+		if opts.Refine {
+			return errors.New("-refine needs the ciphertext in memory, not -stream")
+		}
+		ranking, candidates, err = breakStream(&input, opts, *out)
+	} else {
+		ranking, candidates, err = breakAll(&input, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	r := &vigenereResult{}
+	for _, s := range ranking {
+		r.KeyLens = append(r.KeyLens, keyLenScore{KeyLen: s.KeyLen, Score: s.Score})
+	}
+	for _, c := range candidates {
+		vc := vigenereCandidate{
+			KeyLen:    c.KeyLen,
+			Score:     c.Score,
+			Key:       hex.EncodeToString(c.Key),
+			Plaintext: string(c.Plaintext),
+		}
+		if *cipher != "xor" {
+			vc.KeyText = string(c.Key)
+		}
+		for i := range c.Found {
+			if !c.Found[i] {
+				vc.Missing = append(vc.Missing, i)
+			}
+		}
+		vc.Confidence = c.Confidence
+		for i, changed := range c.Changed {
+			if changed {
+				vc.Corrected = append(vc.Corrected, i)
+			}
+		}
+		if *alternatives > 0 {
+			for _, rank := range c.Ranks {
+				var alts []keyByteScore
+				n := *alternatives
+				if n > len(rank) {
+					n = len(rank)
+				}
+				for _, k := range rank[:n] {
+					alts = append(alts, keyByteScore{
+						Key:   hex.EncodeToString([]byte{k.Key}),
+						Score: k.Score,
+					})
+				}
+				vc.Alternatives = append(vc.Alternatives, alts)
+			}
+		}
+		r.Candidates = append(r.Candidates, vc)
+	}
+	r.vigenereCandidate = r.Candidates[0]
+	if len(r.Candidates) == 1 {
+		r.Candidates = nil
+	}
+	return emit(*output, r)
+}
+
+// breakAll breaks the ciphertext loaded in memory
+func breakAll(input *inputFlags, opts vigenere.Options) ([]vigenere.KeyLenScore, []vigenere.Candidate, error) {
+	ct, err := input.bytes()
+	if err != nil {
+		return nil, nil, err
+	}
+	ranking, err := vigenere.RankKeyLens(ct, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	candidates, err := vigenere.BreakWith(ct, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ranking, candidates, nil
+}
+
+// breakStream breaks the ciphertext read as a stream, then reads it again
+// to write the plaintext of the best candidate to out (if not empty)
+func breakStream(input *inputFlags, opts vigenere.Options, out string) ([]vigenere.KeyLenScore, []vigenere.Candidate, error) {
+	if out != "" && input.file == "" {
+		return nil, nil, errors.New("-out needs the ciphertext to be read with -file")
+	}
+	a, err := vigenere.NewAnalyzer(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := input.open()
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = io.Copy(a, r)
+	r.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	ranking, err := a.RankKeyLens()
+	if err != nil {
+		return nil, nil, err
+	}
+	candidates, err := a.Candidates()
+	if err != nil {
+		return nil, nil, err
+	}
+	if out == "" {
+		return ranking, candidates, nil
+	}
+
+	r, err = input.open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+	f, err := os.Create(out)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = io.Copy(f, vigenere.NewDecryptReader(r, candidates[0].Key, opts.Cipher))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return ranking, candidates, nil
+}
+
+func readNgramTable(name string) (*langmodel.NgramModel, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return langmodel.ReadNgramTable(f, langmodel.LETTERS)
+}
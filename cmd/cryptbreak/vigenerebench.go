@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gpdionisio/umcp_cryptography/week_01-vigenere"
+)
+
+// intList is a comma-separated list of positive integers
+type intList []int
+
+func (l *intList) String() string {
+	s := make([]string, len(*l))
+	for i, n := range *l {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *intList) Set(s string) error {
+	*l = nil
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid length %q", f)
+		}
+		*l = append(*l, n)
+	}
+	return nil
+}
+
+type successRate struct {
+	CtLen        int     `json:"ct_len"`
+	KeyLen       int     `json:"key_len"`
+	Trials       int     `json:"trials"`
+	KeyLenFound  int     `json:"key_len_found"`
+	Broken       int     `json:"broken"`
+	Rate         float64 `json:"rate"`
+	ByteAccuracy float64 `json:"byte_accuracy"`
+}
+
+type benchResult struct {
+	Rates []successRate `json:"rates"`
+}
+
+func (r *benchResult) printText(w io.Writer) {
+	fmt.Fprintf(w, "%7s %7s %6s %9s %6s %6s %8s\n",
+		"ct_len", "key_len", "trials", "keylen_ok", "broken", "rate", "byte_acc")
+	for _, s := range r.Rates {
+		fmt.Fprintf(w, "%7d %7d %6d %9d %6d %6.2f %8.4f\n",
+			s.CtLen, s.KeyLen, s.Trials, s.KeyLenFound, s.Broken, s.Rate, s.ByteAccuracy)
+	}
+}
+
+func runVigenereBench(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("vigenere-bench", flag.ExitOnError)
+	corpusFile := fs.String("corpus", "", "plaintext corpus (the embedded "+
+		"English sample if empty)")
+	cipher := fs.String("cipher", "xor", "cipher: xor, vigenere, beaufort, "+
+		"variant-beaufort or autokey")
+	ctLens := intList{50, 100, 200, 500, 1000}
+	fs.Var(&ctLens, "ct-lens", "ciphertext lengths")
+	keyLens := intList{3, 5, 7, 13}
+	fs.Var(&keyLens, "key-lens", "key lengths")
+	trials := fs.Int("trials", 20, "samples for each pair of lengths")
+	seed := fs.Int64("seed", 1, "seed of the generator")
+	var opts vigenere.Options
+	fs.IntVar(&opts.MaxKeyLen, "max-key-len", 0, "longest key length searched "+
+		"(the longest of -key-lens if zero)")
+	fs.IntVar(&opts.TopN, "top", 1, "number of best ranked key lengths tried")
+	estimator := fs.String("keylen", "", "key length estimator (see vigenere)")
+	scoring := fs.String("scoring", "chi2", "key byte scoring: chi2, dot or charset")
+	fs.BoolVar(&opts.Refine, "refine", false, "refine the keys with hill "+
+		"climbing on an n-gram model")
+	ngramTable := fs.String("ngram-table", "", "n-gram table for -refine "+
+		"(the embedded English trigrams if empty)")
+	output := registerOutput(fs)
+	fs.Parse(args)
+
+	corpus := vigenere.CORPUS
+	if *corpusFile != "" {
+		var err error
+		corpus, err = os.ReadFile(*corpusFile)
+		if err != nil {
+			return err
+		}
+	}
+	c, err := vigenere.ParseCipher(*cipher)
+	if err != nil {
+		return err
+	}
+	if *estimator != "" {
+		opts.Estimator, err = vigenere.ParseEstimator(*estimator)
+		if err != nil {
+			return err
+		}
+	}
+	opts.Scoring, err = vigenere.ParseScoring(*scoring)
+	if err != nil {
+		return err
+	}
+	if *ngramTable != "" {
+		opts.Model, err = readNgramTable(*ngramTable)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.MaxKeyLen == 0 {
+		for _, L := range keyLens {
+			if L > opts.MaxKeyLen {
+				opts.MaxKeyLen = L
+			}
+		}
+	}
+
+	g := vigenere.NewGenerator(corpus, c, *seed)
+	rates, err := vigenere.Measure(g, ctLens, keyLens, *trials, opts)
+	if err != nil {
+		return err
+	}
+	r := &benchResult{}
+	for _, s := range rates {
+		r.Rates = append(r.Rates, successRate{
+			CtLen:        s.CtLen,
+			KeyLen:       s.KeyLen,
+			Trials:       s.Trials,
+			KeyLenFound:  s.KeyLenFound,
+			Broken:       s.Broken,
+			Rate:         s.Rate(),
+			ByteAccuracy: s.ByteAccuracy,
+		})
+	}
+	return emit(*output, r)
+}
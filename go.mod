@@ -0,0 +1,3 @@
+module github.com/gpdionisio/umcp_cryptography
+
+go 1.18
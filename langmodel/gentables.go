@@ -0,0 +1,92 @@
+//go:build ignore
+
+// Gentables writes the embedded trigram tables, trigrams.txt (LETTERS)
+// and shapes.txt (SHAPES), from the prose of a training text. The text is
+// held out from week_01-vigenere/corpus.txt, on which the breaker is benchmarked.
+//
+// Usage:
+//
+//	go run gentables.go -in $GOROOT/src/testdata/Isaac.Newton-Opticks.txt
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/gpdionisio/umcp_cryptography/langmodel"
+)
+
+// isLetter tells whether b is an ASCII letter
+func isLetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// isProse tells whether a paragraph is prose rather than a table, a
+// figure or a heading: at least 200 bytes, with under 2% of its trigrams
+// free of letters
+func isProse(p []byte) bool {
+	if len(p) < 200 {
+		return false
+	}
+	n := 0
+	for i := 0; i+3 <= len(p); i++ {
+		if !isLetter(p[i]) && !isLetter(p[i+1]) && !isLetter(p[i+2]) {
+			n++
+		}
+	}
+	return float64(n) < 0.02*float64(len(p)-2)
+}
+
+// unwrap joins the lines of each paragraph of prose and drops the '_'
+// marking the italics, so that the text reads like that corpus
+func unwrap(text []byte) []byte {
+	text = bytes.ReplaceAll(text, []byte("\r\n"), []byte("\n"))
+	text = bytes.ReplaceAll(text, []byte("_"), nil)
+	paragraphs := regexp.MustCompile(`\n\s*\n`).Split(string(text), -1)
+	var out bytes.Buffer
+	for _, p := range paragraphs {
+		p := bytes.Join(bytes.Fields([]byte(p)), []byte(" "))
+		if !isProse(p) {
+			continue
+		}
+		out.Write(p)
+		out.WriteString("\n\n")
+	}
+	return out.Bytes()
+}
+
+func main() {
+	in := flag.String("in", "", "training text")
+	flag.Parse()
+	text, err := os.ReadFile(*in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	text = unwrap(text)
+	tables := []struct {
+		file     string
+		alphabet *langmodel.Alphabet
+	}{
+		{"trigrams.txt", langmodel.LETTERS},
+		{"shapes.txt", langmodel.SHAPES},
+	}
+	for _, t := range tables {
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "# trigram counts of %s (%d bytes unwrapped), "+
+			"written by gentables.go with WriteTable(w, text, 3, %s)\n",
+			filepath.Base(*in), len(text), t.alphabet.Name)
+		if err := langmodel.WriteTable(&buf, text, 3, t.alphabet); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(t.file, buf.Bytes(), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
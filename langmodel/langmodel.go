@@ -0,0 +1,26 @@
+// Package langmodel models the plaintexts the attacks recover: unigram
+// frequencies and character n-grams of English prose, shared by the
+// Vigenere breaker, the many-time pad solver and the padding oracle.
+package langmodel
+
+import "math"
+
+// MAX_PASSES bounds the passes of the hill climbing over a key
+// (e.g. vigenere.Refine and manytimepad.Solve)
+const MAX_PASSES = 10 //nolint
+
+// LogSumExp returns log(sum(exp(x))) of the log-likelihoods, without
+// overflow
+func LogSumExp(xs []float64) float64 {
+	max := math.Inf(-1)
+	for _, x := range xs {
+		if x > max {
+			max = x
+		}
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += math.Exp(x - max)
+	}
+	return max + math.Log(sum)
+}
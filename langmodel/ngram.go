@@ -0,0 +1,270 @@
+package langmodel
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Alphabet maps the bytes of a text to the symbols of an n-gram model,
+// written in the tables with the characters of Chars
+type Alphabet struct {
+	Name  string
+	Chars string
+	Fold  func(b byte) int
+}
+
+// LETTERS is the alphabet of the 26 letters regardless of their case, a
+// separator for spaces, punctuation and digits, and a symbol for the bytes
+// never found in text
+var LETTERS = &Alphabet{
+	Name:  "letters",
+	Chars: "abcdefghijklmnopqrstuvwxyz_#",
+	Fold: func(b byte) int {
+		switch {
+		case b >= 'a' && b <= 'z':
+			return int(b - 'a')
+		case b >= 'A' && b <= 'Z':
+			return int(b - 'A')
+		case b >= 0x20 && b < 0x7F, b == '\n', b == '\r', b == '\t':
+			return 26
+		}
+		return 27
+	},
+}
+
+// SHAPES is the alphabet of the classes of characters: lower-case letter,
+// upper-case letter, space, period, comma, newline, digit, other printable
+// character and bytes never found in text. Its n-grams model the case and
+// punctuation that LETTERS ignores.
+var SHAPES = &Alphabet{
+	Name:  "shapes",
+	Chars: "lu_.,$9%#",
+	Fold: func(b byte) int {
+		switch {
+		case b >= 'a' && b <= 'z':
+			return 0
+		case b >= 'A' && b <= 'Z':
+			return 1
+		case b == ' ', b == '\t':
+			return 2
+		case b == '.':
+			return 3
+		case b == ',':
+			return 4
+		case b == '\n', b == '\r':
+			return 5
+		case b >= '0' && b <= '9':
+			return 6
+		case b > 0x20 && b < 0x7F:
+			return 7
+		}
+		return 8
+	},
+}
+
+func (a *Alphabet) size() int {
+	return len(a.Chars)
+}
+
+// NgramModel is a character n-gram language model: the log-probability
+// of each n-gram of the symbols of an alphabet, with a floor for the
+// unseen ones
+type NgramModel struct {
+	N        int
+	Alphabet *Alphabet
+	logp     []float64 // indexed by the n-gram as a number in base alphabet size
+}
+
+// trigram counts of a training text held out from the benchmark corpus
+// (vigenere.CORPUS), written by gentables.go
+//
+//go:generate go run gentables.go -in $GOROOT/src/testdata/Isaac.Newton-Opticks.txt
+var (
+	//go:embed trigrams.txt
+	trigramTable string
+	//go:embed shapes.txt
+	shapeTable string
+)
+
+// ENGLISH_NGRAMS is the default model, the LETTERS trigrams of English prose
+var ENGLISH_NGRAMS = mustReadTable(trigramTable, LETTERS)
+
+// ENGLISH_SHAPES is the SHAPES trigrams of English prose
+var ENGLISH_SHAPES = mustReadTable(shapeTable, SHAPES)
+
+func mustReadTable(table string, alphabet *Alphabet) *NgramModel {
+	m, err := ReadNgramTable(strings.NewReader(table), alphabet)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// countNgrams counts the n-grams of a sample of text
+func countNgrams(sample []byte, n int, alphabet *Alphabet) []uint {
+	counts := make([]uint, intPow(alphabet.size(), n))
+	size := len(counts)
+	gram := 0
+	for i, b := range sample {
+		gram = (gram*alphabet.size() + alphabet.Fold(b)) % size
+		if i >= n-1 {
+			counts[gram]++
+		}
+	}
+	return counts
+}
+
+// newNgramModel computes the log-probabilities from the counts
+func newNgramModel(counts []uint, n int, alphabet *Alphabet) *NgramModel {
+	var tot uint
+	for _, c := range counts {
+		tot += c
+	}
+	m := &NgramModel{N: n, Alphabet: alphabet, logp: make([]float64, len(counts))}
+	floor := math.Log(0.01 / float64(tot+1))
+	for i, c := range counts {
+		if c == 0 {
+			m.logp[i] = floor
+			continue
+		}
+		m.logp[i] = math.Log(float64(c) / float64(tot))
+	}
+	return m
+}
+
+// NewNgramModel computes the model of the n-grams (n from 2 to 4) of
+// a sample of text
+func NewNgramModel(sample []byte, n int, alphabet *Alphabet) (*NgramModel, error) {
+	if n < 2 || n > 4 {
+		return nil, fmt.Errorf("invalid n-gram length %d (must be from 2 to 4)", n)
+	}
+	return newNgramModel(countNgrams(sample, n, alphabet), n, alphabet), nil
+}
+
+// WriteTable writes the counts of the n-grams of a sample as lines
+// "ngram count", the symbols written with the characters of the alphabet
+func WriteTable(w io.Writer, sample []byte, n int, alphabet *Alphabet) error {
+	counts := countNgrams(sample, n, alphabet)
+	for i, c := range counts {
+		if c == 0 {
+			continue
+		}
+		gram := make([]byte, n)
+		for j, x := n-1, i; j >= 0; j, x = j-1, x/alphabet.size() {
+			gram[j] = alphabet.Chars[x%alphabet.size()]
+		}
+		if _, err := fmt.Fprintf(w, "%s %d\n", gram, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadNgramTable reads the model from a table written by WriteTable
+// with the same alphabet (lines starting with '#' followed by a space
+// are comments)
+func ReadNgramTable(r io.Reader, alphabet *Alphabet) (*NgramModel, error) {
+	var counts []uint
+	n := 0
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "# ") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"ngram count\"", line)
+		}
+		gram, err := alphabet.parseGram(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if n == 0 {
+			n = len(fields[0])
+			if n < 2 || n > 4 {
+				return nil, fmt.Errorf("line %d: invalid n-gram length %d", line, n)
+			}
+			counts = make([]uint, intPow(alphabet.size(), n))
+		} else if len(fields[0]) != n {
+			return nil, fmt.Errorf("line %d: n-gram %q is not of length %d",
+				line, fields[0], n)
+		}
+		c, err := strconv.ParseUint(fields[1], 10, 0)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		counts[gram] += uint(c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("empty n-gram table")
+	}
+	return newNgramModel(counts, n, alphabet), nil
+}
+
+func (a *Alphabet) parseGram(s string) (int, error) {
+	gram := 0
+	for i := 0; i < len(s); i++ {
+		x := strings.IndexByte(a.Chars, s[i])
+		if x < 0 {
+			return 0, fmt.Errorf("invalid n-gram %q for the %s alphabet", s, a.Name)
+		}
+		gram = gram*a.size() + x
+	}
+	return gram, nil
+}
+
+func intPow(b, e int) int {
+	r := 1
+	for i := 0; i < e; i++ {
+		r *= b
+	}
+	return r
+}
+
+// gramAt returns the n-gram of text starting at i
+func (m *NgramModel) gramAt(text []byte, i int) int {
+	gram := 0
+	for j := i; j < i+m.N; j++ {
+		gram = gram*m.Alphabet.size() + m.Alphabet.Fold(text[j])
+	}
+	return gram
+}
+
+// Score returns the log-likelihood of the text
+func (m *NgramModel) Score(text []byte) float64 {
+	var score float64
+	for i := 0; i+m.N <= len(text); i++ {
+		score += m.logp[m.gramAt(text, i)]
+	}
+	return score
+}
+
+// ScoreAround returns the log-likelihood of the n-grams of the text
+// covering any of the (sorted) positions
+func (m *NgramModel) ScoreAround(text []byte, positions []int) float64 {
+	var score float64
+	next := 0 // first n-gram not scored yet
+	for _, p := range positions {
+		start := p - m.N + 1
+		if start < next {
+			start = next
+		}
+		if start < 0 {
+			start = 0
+		}
+		for i := start; i <= p && i+m.N <= len(text); i++ {
+			score += m.logp[m.gramAt(text, i)]
+		}
+		next = p + 1
+	}
+	return score
+}
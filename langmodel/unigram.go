@@ -0,0 +1,68 @@
+package langmodel
+
+// Unigrams holds the expected frequency of every byte in the plaintext.
+// No frequency is zero, so that chi-squared is always defined.
+type Unigrams [256]float64
+
+// floor frequency of the bytes never seen in the sample
+const UNSEEN_FREQ = 1e-6 //nolint
+
+// frequencies of the letters in English text, a to z
+var englishLetters = [26]float64{
+	0.0817, 0.0149, 0.0278, 0.0425, 0.1270, 0.0223, 0.0202, 0.0609, 0.0697,
+	0.0015, 0.0077, 0.0403, 0.0241, 0.0675, 0.0751, 0.0193, 0.0010, 0.0599,
+	0.0633, 0.0906, 0.0276, 0.0098, 0.0236, 0.0015, 0.0197, 0.0007,
+}
+
+// ENGLISH is the default table: English prose with mostly lower-case
+// letters and spaces, some punctuation, digits and newlines
+var ENGLISH = englishUnigrams()
+
+func englishUnigrams() *Unigrams {
+	var u Unigrams
+	for b := 0x20; b < 0x7F; b++ {
+		u[b] = 0.0005 // other printable
+	}
+	for i, f := range englishLetters {
+		u['a'+i] = f * 0.72
+		u['A'+i] = f * 0.03
+	}
+	for b := '0'; b <= '9'; b++ {
+		u[b] = 0.0005
+	}
+	u[' '] = 0.17
+	u[','] = 0.012
+	u['.'] = 0.011
+	u['\''] = 0.004
+	u['"'] = 0.003
+	u['-'] = 0.003
+	u['\n'] = 0.01
+	u.normalize()
+	return &u
+}
+
+// NewUnigrams computes the table from a sample of plaintext
+func NewUnigrams(sample []byte) *Unigrams {
+	var u Unigrams
+	for _, b := range sample {
+		u[b]++
+	}
+	u.normalize()
+	return &u
+}
+
+// normalize makes the frequencies sum to 1, with none below UNSEEN_FREQ
+func (u *Unigrams) normalize() {
+	var tot float64
+	for _, f := range u {
+		tot += f
+	}
+	for b := range u {
+		if tot > 0 {
+			u[b] /= tot
+		}
+		if u[b] < UNSEEN_FREQ {
+			u[b] = UNSEEN_FREQ
+		}
+	}
+}
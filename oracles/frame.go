@@ -0,0 +1,122 @@
+package oracles
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// DEFAULT_IDLE ends the unterminated frames when no idle time is
+// configured
+const DEFAULT_IDLE = 200 * time.Millisecond //nolint
+
+// FramedConn reads length-exact and unterminated frames from
+// an oracle connection, however the bytes are split by the network
+type FramedConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewFramedConn wraps a connection
+func NewFramedConn(conn net.Conn) *FramedConn {
+	return &FramedConn{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// Close closes the underlying connection
+func (c *FramedConn) Close() error {
+	return c.conn.Close()
+}
+
+// Write sends the whole packet, bounded by timeout and ctx
+func (c *FramedConn) Write(ctx context.Context, buf []byte,
+	timeout time.Duration) error {
+	return WithDeadline(ctx, "write", timeout, c.conn.SetWriteDeadline,
+		func() error {
+			_, err := c.conn.Write(buf)
+			return err
+		})
+}
+
+// ReadFull reads exactly n bytes, bounded by timeout and ctx.
+// A connection closed before n bytes are read is an io.ErrUnexpectedEOF.
+func (c *FramedConn) ReadFull(ctx context.Context, n int,
+	timeout time.Duration) ([]byte, error) {
+	buf := make([]byte, n)
+	err := WithDeadline(ctx, "read", timeout, c.conn.SetReadDeadline,
+		func() error {
+			_, err := io.ReadFull(c.r, buf)
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		})
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// ReadUnterminated reads a frame sent without length nor delimiter, bounded
+// by timeout and ctx: it ends as soon as complete returns true (complete
+// may be nil), or once the connection stays idle for idle (DEFAULT_IDLE if
+// not positive) after the first byte. The idle waits never go past the
+// earlier of the ctx deadline and timeout, so a trickling server cannot
+// stretch the read. Frames longer than max bytes are an error.
+func (c *FramedConn) ReadUnterminated(ctx context.Context, max int,
+	idle, timeout time.Duration, complete func(frame []byte) bool) ([]byte, error) {
+	if idle <= 0 {
+		idle = DEFAULT_IDLE
+	}
+	deadline := deadlineOf(ctx, timeout)
+	var buf []byte
+	err := WithDeadline(ctx, "read", timeout, c.conn.SetReadDeadline,
+		func() error {
+			for {
+				if len(buf) > 0 && c.r.Buffered() == 0 {
+					// wait for more bytes, at most idle
+					wait := time.Now().Add(idle)
+					if wait.After(deadline) {
+						wait = deadline
+					}
+					if err := c.conn.SetReadDeadline(wait); err != nil {
+						return err
+					}
+					// a cancellation before the new deadline was set
+					// would otherwise wait for the idle period
+					if err := ctx.Err(); err != nil {
+						return err
+					}
+				}
+				b, err := c.r.ReadByte()
+				if len(buf) > 0 && errors.Is(err, os.ErrDeadlineExceeded) &&
+					time.Now().Before(deadline) && ctx.Err() == nil {
+					return nil
+				}
+				if err == io.EOF && len(buf) > 0 {
+					return nil
+				}
+				if err == io.EOF {
+					return io.ErrUnexpectedEOF
+				}
+				if err != nil {
+					return err
+				}
+				if len(buf) == max {
+					return fmt.Errorf("frame longer than %d bytes", max)
+				}
+				buf = append(buf, b)
+				if complete != nil && complete(buf) {
+					return nil
+				}
+			}
+		})
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
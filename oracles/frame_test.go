@@ -0,0 +1,157 @@
+package oracles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// pipe returns the client end of an in-memory connection and the server end
+// closed at the end of the test
+func pipe(t *testing.T) (*FramedConn, net.Conn) {
+	t.Helper()
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return NewFramedConn(client), server
+}
+
+// trickle writes the bytes one at a time, then closes the connection if
+// hangup is set
+func trickle(conn net.Conn, b []byte, hangup bool) {
+	for i := range b {
+		if _, err := conn.Write(b[i : i+1]); err != nil {
+			return
+		}
+	}
+	if hangup {
+		conn.Close()
+	}
+}
+
+func TestFramedConn(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ReadFull", func(t *testing.T) {
+		c, server := pipe(t)
+		go trickle(server, []byte("0123456789abcdefXY"), false)
+		got, err := c.ReadFull(ctx, 16, time.Second)
+		if err != nil || string(got) != "0123456789abcdef" {
+			t.Fatalf("ReadFull(16) = %q, %v", got, err)
+		}
+		// the bytes read ahead are kept for the next frame
+		got, err = c.ReadFull(ctx, 2, time.Second)
+		if err != nil || string(got) != "XY" {
+			t.Errorf("ReadFull(2) = %q, %v", got, err)
+		}
+	})
+
+	t.Run("ReadFull/closed", func(t *testing.T) {
+		c, server := pipe(t)
+		go trickle(server, []byte("0"), true)
+		if _, err := c.ReadFull(ctx, 2, time.Second); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("got %v, want %v", err, io.ErrUnexpectedEOF)
+		}
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		c, server := pipe(t)
+		go c.Write(ctx, []byte("packet"), time.Second)
+		got := make([]byte, 6)
+		if _, err := io.ReadFull(server, got); err != nil || string(got) != "packet" {
+			t.Errorf("wrote %q, %v", got, err)
+		}
+	})
+
+	t.Run("ReadUnterminated/complete", func(t *testing.T) {
+		c, server := pipe(t)
+		go trickle(server, []byte("abc\ndef"), false)
+		got, err := c.ReadUnterminated(ctx, 16, time.Second, time.Second,
+			func(frame []byte) bool { return bytes.HasSuffix(frame, []byte("\n")) })
+		if err != nil || string(got) != "abc\n" {
+			t.Errorf("ReadUnterminated = %q, %v", got, err)
+		}
+	})
+
+	t.Run("ReadUnterminated/idle", func(t *testing.T) {
+		c, server := pipe(t)
+		go trickle(server, []byte("abc"), false)
+		got, err := c.ReadUnterminated(ctx, 16, 50*time.Millisecond, time.Second, nil)
+		if err != nil || string(got) != "abc" {
+			t.Errorf("ReadUnterminated = %q, %v", got, err)
+		}
+	})
+
+	t.Run("ReadUnterminated/closed", func(t *testing.T) {
+		c, server := pipe(t)
+		go trickle(server, []byte("abc"), true)
+		got, err := c.ReadUnterminated(ctx, 16, time.Second, time.Second, nil)
+		if err != nil || string(got) != "abc" {
+			t.Errorf("ReadUnterminated = %q, %v", got, err)
+		}
+	})
+
+	t.Run("ReadUnterminated/max", func(t *testing.T) {
+		c, server := pipe(t)
+		go trickle(server, []byte("abcdef"), false)
+		if _, err := c.ReadUnterminated(ctx, 4, time.Second, time.Second, nil); err == nil {
+			t.Error("frame of 6 bytes read with max 4")
+		}
+	})
+}
+
+func TestReadUnterminatedCancel(t *testing.T) {
+	c, server := pipe(t)
+	go server.Write([]byte("1"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Now()
+	// cancelled while the first idle wait is being set up
+	_, err := c.ReadUnterminated(ctx, 16, 10*time.Second, time.Minute,
+		func([]byte) bool {
+			cancel()
+			return false
+		})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("returned after %v, not when cancelled", d)
+	}
+}
+
+func TestReadUnterminatedTrickle(t *testing.T) {
+	c, server := pipe(t)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+				if _, err := server.Write([]byte("1")); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	start := time.Now()
+	_, err := c.ReadUnterminated(context.Background(), 1024,
+		200*time.Millisecond, 300*time.Millisecond, nil)
+	var terr *TimeoutError
+	if !errors.As(err, &terr) {
+		t.Errorf("got %v, want a timeout", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("returned after %v, past the 300ms timeout", d)
+	}
+}
@@ -0,0 +1,109 @@
+package oracles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// DEFAULT_TIMEOUT bounds dial, read and write operations when no timeout
+// is configured
+const DEFAULT_TIMEOUT = 30 * time.Second //nolint
+
+// TimeoutError is returned when an oracle does not answer in time
+// (either the operation timeout or the context deadline expired)
+type TimeoutError struct {
+	Op  string
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out: %v", e.Op, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+// Timeout reports true, so that TimeoutError satisfies net.Error
+func (e *TimeoutError) Timeout() bool { return true }
+
+// Temporary reports false, a timed out exchange leaves the connection
+// in an unknown state
+func (e *TimeoutError) Temporary() bool { return false }
+
+func timeoutOrDefault(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return DEFAULT_TIMEOUT
+	}
+	return timeout
+}
+
+// wrapErr turns deadline errors into a TimeoutError
+func wrapErr(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var nerr net.Error
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, os.ErrDeadlineExceeded) ||
+		(errors.As(err, &nerr) && nerr.Timeout()) {
+		return &TimeoutError{Op: op, Err: err}
+	}
+	return err
+}
+
+// Dial connects to the TCP address, giving up after timeout
+// (DEFAULT_TIMEOUT if not positive) or when ctx is done
+func Dial(ctx context.Context, addr string, timeout time.Duration) (net.Conn, error) {
+	d := net.Dialer{Timeout: timeoutOrDefault(timeout)}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	return conn, wrapErr("dial "+addr, err)
+}
+
+// deadlineOf returns the time after timeout (DEFAULT_TIMEOUT if not
+// positive) or the deadline of ctx, whichever comes first
+func deadlineOf(ctx context.Context, timeout time.Duration) time.Time {
+	deadline := time.Now().Add(timeoutOrDefault(timeout))
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	return deadline
+}
+
+// WithDeadline runs the I/O operation f after setting a deadline of
+// timeout (DEFAULT_TIMEOUT if not positive) or the deadline of ctx,
+// whichever comes first. If ctx is cancelled while f is blocked, the
+// deadline is moved to the past so that f returns immediately.
+// setDeadline is typically the SetReadDeadline or SetWriteDeadline method
+// of the connection.
+func WithDeadline(ctx context.Context, op string, timeout time.Duration,
+	setDeadline func(time.Time) error, f func() error) error {
+	if err := ctx.Err(); err != nil {
+		return wrapErr(op, err)
+	}
+	if err := setDeadline(deadlineOf(ctx, timeout)); err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	watcherDone := make(chan struct{})
+	go func() {
+		defer close(watcherDone)
+		select {
+		case <-ctx.Done():
+			setDeadline(time.Unix(1, 0)) //nolint
+		case <-stop:
+		}
+	}()
+	err := f()
+	close(stop)
+	<-watcherDone
+
+	if err != nil && ctx.Err() != nil {
+		// report the reason of the interruption
+		return wrapErr(op, ctx.Err())
+	}
+	return wrapErr(op, err)
+}
@@ -0,0 +1,62 @@
+// Package oracles defines the oracles the attacks are run against, so that
+// they can be driven by a remote server (TCP), by a local implementation
+// (in-process) or by a recorded transcript.
+package oracles
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrMalformed is returned when the server rejects a query as malformed
+var ErrMalformed = errors.New("malformed packet")
+
+// PaddingOracle tells whether a CBC ciphertext < IV || C_1 || ... || C_n >
+// decrypts to a plaintext with valid padding.
+// All the oracles give up when ctx is done.
+type PaddingOracle interface {
+	ValidPadding(ctx context.Context, ct []byte) (bool, error)
+}
+
+// MACOracle returns the tag of a message
+type MACOracle interface {
+	Tag(ctx context.Context, mess []byte) ([]byte, error)
+}
+
+// VerifyOracle checks whether a tag (or signature) is valid for a message
+type VerifyOracle interface {
+	Verify(ctx context.Context, mess, tag []byte) (bool, error)
+}
+
+// SigningOracle returns the signature of a message
+type SigningOracle interface {
+	Signature(ctx context.Context, mess []byte) ([]byte, error)
+}
+
+// PaddingOracleFunc adapts a function to a PaddingOracle
+type PaddingOracleFunc func(ctx context.Context, ct []byte) (bool, error)
+
+func (f PaddingOracleFunc) ValidPadding(ctx context.Context, ct []byte) (bool, error) {
+	return f(ctx, ct)
+}
+
+// MACOracleFunc adapts a function to a MACOracle
+type MACOracleFunc func(ctx context.Context, mess []byte) ([]byte, error)
+
+func (f MACOracleFunc) Tag(ctx context.Context, mess []byte) ([]byte, error) {
+	return f(ctx, mess)
+}
+
+// VerifyOracleFunc adapts a function to a VerifyOracle
+type VerifyOracleFunc func(ctx context.Context, mess, tag []byte) (bool, error)
+
+func (f VerifyOracleFunc) Verify(ctx context.Context, mess, tag []byte) (bool, error) {
+	return f(ctx, mess, tag)
+}
+
+// SigningOracleFunc adapts a function to a SigningOracle
+type SigningOracleFunc func(ctx context.Context, mess []byte) ([]byte, error)
+
+func (f SigningOracleFunc) Signature(ctx context.Context, mess []byte) ([]byte, error) {
+	return f(ctx, mess)
+}
@@ -0,0 +1,240 @@
+package oracles
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	OP_VALID_PADDING = "valid_padding" //nolint
+	OP_TAG           = "tag"           //nolint
+	OP_VERIFY        = "verify"        //nolint
+	OP_SIGNATURE     = "signature"     //nolint
+)
+
+var ErrNotRecorded = errors.New("query not found in transcript")
+
+// errorKinds maps the kinds of the recorded errors to their sentinels
+var (
+	kindsMu    sync.RWMutex
+	errorKinds = map[string]error{"malformed": ErrMalformed}
+)
+
+// RegisterError gives a stable kind to a sentinel error, so that the
+// errors matching it are replayed as errors matching it again
+// (errors.Is). It is meant to be called from init functions.
+func RegisterError(kind string, sentinel error) {
+	kindsMu.Lock()
+	defer kindsMu.Unlock()
+	errorKinds[kind] = sentinel
+}
+
+// errorKind returns the kind of the first registered sentinel matched by
+// err, "" if none
+func errorKind(err error) string {
+	kindsMu.RLock()
+	defer kindsMu.RUnlock()
+	kinds := make([]string, 0, len(errorKinds))
+	for kind := range errorKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		if errors.Is(err, errorKinds[kind]) {
+			return kind
+		}
+	}
+	return ""
+}
+
+// replayedError is a recorded error, wrapping its sentinel if any
+type replayedError struct {
+	msg      string
+	sentinel error
+}
+
+func (e *replayedError) Error() string { return e.msg }
+func (e *replayedError) Unwrap() error { return e.sentinel }
+
+// Exchange is a single query to an oracle with its answer
+type Exchange struct {
+	Op      string   `json:"op"`
+	Input   [][]byte `json:"input"`
+	Output  []byte   `json:"output,omitempty"`
+	Err     string   `json:"err,omitempty"`
+	ErrKind string   `json:"err_kind,omitempty"` // see RegisterError
+}
+
+func (e Exchange) key() string {
+	parts := []string{e.Op}
+	for _, in := range e.Input {
+		parts = append(parts, hex.EncodeToString(in))
+	}
+	return strings.Join(parts, ":")
+}
+
+func (e Exchange) error() error {
+	if e.Err == "" && e.ErrKind == "" {
+		return nil
+	}
+	kindsMu.RLock()
+	sentinel := errorKinds[e.ErrKind]
+	kindsMu.RUnlock()
+	return &replayedError{msg: e.Err, sentinel: sentinel}
+}
+
+// WriteTranscript writes the exchanges as JSON, one per line
+func WriteTranscript(w io.Writer, exchanges []Exchange) error {
+	enc := json.NewEncoder(w)
+	for _, e := range exchanges {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadTranscript reads the exchanges written by WriteTranscript
+func ReadTranscript(r io.Reader) ([]Exchange, error) {
+	var exchanges []Exchange
+	dec := json.NewDecoder(r)
+	for {
+		var e Exchange
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			return exchanges, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		exchanges = append(exchanges, e)
+	}
+}
+
+// Recorder forwards the queries to the wrapped oracles and records every
+// exchange. Only the oracles that are actually queried need to be set.
+type Recorder struct {
+	Padding  PaddingOracle
+	MAC      MACOracle
+	Verifier VerifyOracle
+	Signer   SigningOracle
+
+	mu        sync.Mutex
+	exchanges []Exchange
+}
+
+// Exchanges returns the exchanges recorded so far
+func (r *Recorder) Exchanges() []Exchange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Exchange(nil), r.exchanges...)
+}
+
+// record stores an exchange, unless it was interrupted by ctx
+func (r *Recorder) record(ctx context.Context, op string, out []byte,
+	err error, in ...[]byte) {
+	if ctx.Err() != nil {
+		return
+	}
+	e := Exchange{Op: op, Output: out}
+	for _, b := range in {
+		e.Input = append(e.Input, append([]byte(nil), b...))
+	}
+	if err != nil {
+		e.Err = err.Error()
+		e.ErrKind = errorKind(err)
+	}
+	r.mu.Lock()
+	r.exchanges = append(r.exchanges, e)
+	r.mu.Unlock()
+}
+
+func (r *Recorder) ValidPadding(ctx context.Context, ct []byte) (bool, error) {
+	ok, err := r.Padding.ValidPadding(ctx, ct)
+	r.record(ctx, OP_VALID_PADDING, boolToBytes(ok), err, ct)
+	return ok, err
+}
+
+func (r *Recorder) Tag(ctx context.Context, mess []byte) ([]byte, error) {
+	tag, err := r.MAC.Tag(ctx, mess)
+	r.record(ctx, OP_TAG, tag, err, mess)
+	return tag, err
+}
+
+func (r *Recorder) Verify(ctx context.Context, mess, tag []byte) (bool, error) {
+	ok, err := r.Verifier.Verify(ctx, mess, tag)
+	r.record(ctx, OP_VERIFY, boolToBytes(ok), err, mess, tag)
+	return ok, err
+}
+
+func (r *Recorder) Signature(ctx context.Context, mess []byte) ([]byte, error) {
+	sig, err := r.Signer.Signature(ctx, mess)
+	r.record(ctx, OP_SIGNATURE, sig, err, mess)
+	return sig, err
+}
+
+// Replayer answers the queries from a recorded transcript, returning
+// ErrNotRecorded for queries that were never made
+type Replayer struct {
+	answers map[string]Exchange
+}
+
+// NewReplayer creates a replayer for the exchanges. If the same query
+// appears more than once, the last answer wins.
+func NewReplayer(exchanges []Exchange) *Replayer {
+	r := Replayer{answers: make(map[string]Exchange)}
+	for _, e := range exchanges {
+		r.answers[e.key()] = e
+	}
+	return &r
+}
+
+func (r *Replayer) lookup(ctx context.Context, op string,
+	in ...[]byte) (Exchange, error) {
+	if err := ctx.Err(); err != nil {
+		return Exchange{}, err
+	}
+	e, ok := r.answers[Exchange{Op: op, Input: in}.key()]
+	if !ok {
+		return Exchange{}, ErrNotRecorded
+	}
+	return e, e.error()
+}
+
+func (r *Replayer) ValidPadding(ctx context.Context, ct []byte) (bool, error) {
+	e, err := r.lookup(ctx, OP_VALID_PADDING, ct)
+	return bytesToBool(e.Output), err
+}
+
+func (r *Replayer) Tag(ctx context.Context, mess []byte) ([]byte, error) {
+	e, err := r.lookup(ctx, OP_TAG, mess)
+	return e.Output, err
+}
+
+func (r *Replayer) Verify(ctx context.Context, mess, tag []byte) (bool, error) {
+	e, err := r.lookup(ctx, OP_VERIFY, mess, tag)
+	return bytesToBool(e.Output), err
+}
+
+func (r *Replayer) Signature(ctx context.Context, mess []byte) ([]byte, error) {
+	e, err := r.lookup(ctx, OP_SIGNATURE, mess)
+	return e.Output, err
+}
+
+func boolToBytes(b bool) []byte {
+	if b {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+func bytesToBool(b []byte) bool {
+	return bytes.Equal(b, []byte{1})
+}
@@ -0,0 +1,67 @@
+package oracles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// replay writes the exchanges and reads them back, as a transcript file
+func replay(t *testing.T, exchanges []Exchange) *Replayer {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteTranscript(&buf, exchanges); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadTranscript(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewReplayer(read)
+}
+
+func TestReplayErrorKinds(t *testing.T) {
+	errOther := errors.New("some failure")
+	errCustom := errors.New("custom sentinel")
+	RegisterError("test_custom", errCustom)
+
+	ctx := context.Background()
+	rec := &Recorder{MAC: MACOracleFunc(func(ctx context.Context, mess []byte) ([]byte, error) {
+		switch len(mess) {
+		case 1:
+			return nil, fmt.Errorf("%w: length 1", ErrMalformed)
+		case 2:
+			return nil, fmt.Errorf("query: %w", errCustom)
+		case 3:
+			return nil, errOther
+		}
+		return mess, nil
+	})}
+	for n := 1; n <= 4; n++ {
+		rec.Tag(ctx, make([]byte, n))
+	}
+	r := replay(t, rec.Exchanges())
+
+	for _, c := range []struct {
+		n    int
+		want error
+	}{{1, ErrMalformed}, {2, errCustom}} {
+		_, err := r.Tag(ctx, make([]byte, c.n))
+		if !errors.Is(err, c.want) {
+			t.Errorf("Tag(%d bytes): got %v, want %v", c.n, err, c.want)
+		}
+	}
+	_, err := r.Tag(ctx, make([]byte, 3))
+	if err == nil || err.Error() != errOther.Error() || errors.Is(err, ErrMalformed) {
+		t.Errorf("Tag(3 bytes): got %v, want %v", err, errOther)
+	}
+	tag, err := r.Tag(ctx, make([]byte, 4))
+	if err != nil || len(tag) != 4 {
+		t.Errorf("Tag(4 bytes) = %x, %v", tag, err)
+	}
+	if _, err := r.Tag(ctx, make([]byte, 5)); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("Tag(5 bytes): got %v, want %v", err, ErrNotRecorded)
+	}
+}
@@ -0,0 +1,218 @@
+package vigenere
+
+import "fmt"
+
+// Cipher is a polyalphabetic cipher with a key of length L: the symbols
+// of the text are split in L streams, the i-th stream being encrypted
+// with key[i] alone. Bytes that are not symbols are left as they are
+// and do not consume key.
+type Cipher interface {
+	Name() string
+	// Keys returns every possible key symbol
+	Keys() []byte
+	// Symbol returns whether the byte is encrypted
+	Symbol(b byte) bool
+	EncryptStream(stream []byte, k byte) []byte
+	DecryptStream(stream []byte, k byte) []byte
+}
+
+// Xor is the byte-wise XOR variant of the assignment
+type Xor struct{}
+
+func (Xor) Name() string     { return "xor" }
+func (Xor) Symbol(byte) bool { return true }
+
+func (Xor) Keys() []byte {
+	keys := make([]byte, 256)
+	for k := range keys {
+		keys[k] = byte(k)
+	}
+	return keys
+}
+
+func (Xor) EncryptStream(stream []byte, k byte) []byte {
+	return xorArr(stream, k)
+}
+
+func (Xor) DecryptStream(stream []byte, k byte) []byte {
+	return xorArr(stream, k)
+}
+
+// the ciphers on the 26 letters: only letters are encrypted (keeping
+// their case), the key symbols are 'A' to 'Z'
+type alphabetic struct{}
+
+func (alphabetic) Symbol(b byte) bool {
+	return (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z')
+}
+
+// the case of the letters is kept as it is, so it is ignored when scoring
+func (alphabetic) caseless() {}
+
+func (alphabetic) Keys() []byte {
+	keys := make([]byte, 26)
+	for k := range keys {
+		keys[k] = 'A' + byte(k)
+	}
+	return keys
+}
+
+// shiftStream maps every letter x (as 0 to 25) of the stream to
+// f(x) mod 26, keeping its case
+func shiftStream(stream []byte, f func(x int) int) []byte {
+	res := make([]byte, len(stream))
+	for i, b := range stream {
+		base := byte('A')
+		if b >= 'a' {
+			base = 'a'
+		}
+		res[i] = base + byte(((f(int(b-base))%26)+26)%26)
+	}
+	return res
+}
+
+// keyShift returns the key letter (of either case) as 0 to 25
+func keyShift(k byte) int {
+	return int((k | 0x20) - 'a')
+}
+
+// Vigenere is the classic Vigenere cipher, c = p + k (mod 26)
+type Vigenere struct{ alphabetic }
+
+func (Vigenere) Name() string { return "vigenere" }
+
+func (Vigenere) EncryptStream(stream []byte, k byte) []byte {
+	return shiftStream(stream, func(x int) int { return x + keyShift(k) })
+}
+
+func (Vigenere) DecryptStream(stream []byte, k byte) []byte {
+	return shiftStream(stream, func(x int) int { return x - keyShift(k) })
+}
+
+// Beaufort is the Beaufort cipher, c = k - p (mod 26), its own inverse
+type Beaufort struct{ alphabetic }
+
+func (Beaufort) Name() string { return "beaufort" }
+
+func (Beaufort) EncryptStream(stream []byte, k byte) []byte {
+	return shiftStream(stream, func(x int) int { return keyShift(k) - x })
+}
+
+func (b Beaufort) DecryptStream(stream []byte, k byte) []byte {
+	return b.EncryptStream(stream, k)
+}
+
+// VariantBeaufort is the variant Beaufort cipher, c = p - k (mod 26)
+type VariantBeaufort struct{ alphabetic }
+
+func (VariantBeaufort) Name() string { return "variant-beaufort" }
+
+func (VariantBeaufort) EncryptStream(stream []byte, k byte) []byte {
+	return Vigenere{}.DecryptStream(stream, k)
+}
+
+func (VariantBeaufort) DecryptStream(stream []byte, k byte) []byte {
+	return Vigenere{}.EncryptStream(stream, k)
+}
+
+// Autokey is the Vigenere cipher whose key is the primer followed by the
+// plaintext itself: with a primer of length L, p[i] is encrypted with
+// p[i-L], the previous symbol of its stream
+type Autokey struct{ alphabetic }
+
+func (Autokey) Name() string { return "autokey" }
+
+// the next key symbol of a stream is the plaintext symbol
+func (Autokey) chain(k, pt byte) byte { return pt }
+
+func (Autokey) EncryptStream(stream []byte, k byte) []byte {
+	prev := keyShift(k)
+	return shiftStream(stream, func(x int) int {
+		c := x + prev
+		prev = x
+		return c
+	})
+}
+
+func (Autokey) DecryptStream(stream []byte, k byte) []byte {
+	prev := keyShift(k)
+	return shiftStream(stream, func(x int) int {
+		p := ((x-prev)%26 + 26) % 26
+		prev = p
+		return p
+	})
+}
+
+// ParseCipher returns the cipher with the given name
+func ParseCipher(name string) (Cipher, error) {
+	for _, c := range []Cipher{
+		Xor{}, Vigenere{}, Beaufort{}, VariantBeaufort{}, Autokey{},
+	} {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown cipher %q (expected xor, vigenere, "+
+		"beaufort, variant-beaufort or autokey)", name)
+}
+
+// symbols returns the symbols of the text, in order
+func symbols(text []byte, c Cipher) []byte {
+	var res []byte
+	for _, b := range text {
+		if c.Symbol(b) {
+			res = append(res, b)
+		}
+	}
+	return res
+}
+
+// transform applies f to each stream of the symbols of the text, leaving
+// the other bytes as they are
+func transform(text, key []byte, c Cipher, f func(stream []byte, k byte) []byte) []byte {
+	res := make([]byte, len(text))
+	copy(res, text)
+	if len(key) == 0 {
+		return res
+	}
+	// positions of the symbols of each stream
+	pos := make([][]int, len(key))
+	streams := make([][]byte, len(key))
+	n := 0
+	for i, b := range text {
+		if c.Symbol(b) {
+			pos[n%len(key)] = append(pos[n%len(key)], i)
+			streams[n%len(key)] = append(streams[n%len(key)], b)
+			n++
+		}
+	}
+	for j := range key {
+		for i, b := range f(streams[j], key[j]) {
+			res[pos[j][i]] = b
+		}
+	}
+	return res
+}
+
+// Encrypt encrypts the plaintext with the key
+func Encrypt(pt, key []byte, c Cipher) []byte {
+	return transform(pt, key, c, c.EncryptStream)
+}
+
+// Decrypt decrypts the ciphertext with the key
+func Decrypt(ct, key []byte, c Cipher) []byte {
+	return transform(ct, key, c, c.DecryptStream)
+}
+
+// decryptTable maps every byte to its decryption with the key symbol
+// (the bytes that are not symbols are left as they are)
+func decryptTable(c Cipher, k byte) *[256]byte {
+	var table [256]byte
+	for b := range table {
+		table[b] = byte(b)
+		if c.Symbol(byte(b)) {
+			table[b] = c.DecryptStream([]byte{byte(b)}, k)[0]
+		}
+	}
+	return &table
+}
@@ -0,0 +1,52 @@
+package vigenere
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	pt := []byte("Attack at dawn, the bridge is the target! (0xFF: \xff\x00)")
+	for _, name := range []string{"xor", "vigenere", "beaufort", "variant-beaufort", "autokey"} {
+		c, err := ParseCipher(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys := c.Keys()
+		for _, key := range [][]byte{
+			{keys[1]},
+			{keys[11], keys[4], keys[12], keys[14], keys[13]},
+			{keys[25], keys[0], keys[7], keys[3], keys[19], keys[8], keys[2]},
+		} {
+			ct := Encrypt(pt, key, c)
+			if bytes.Equal(ct, pt) {
+				t.Errorf("%s, key %q: plaintext left unchanged", name, key)
+			}
+			for i, b := range pt {
+				if !c.Symbol(b) && ct[i] != b {
+					t.Errorf("%s, key %q: byte %d (%q) encrypted", name, key, i, b)
+				}
+			}
+			if got := Decrypt(ct, key, c); !bytes.Equal(got, pt) {
+				t.Errorf("%s, key %q: Decrypt(Encrypt) = %q, want %q", name, key, got, pt)
+			}
+		}
+	}
+}
+
+func TestBeaufortVariants(t *testing.T) {
+	pt := []byte("Attack at dawn")
+	key := []byte("LEMON")
+	if got := Encrypt(pt, key, Vigenere{}); string(got) != "Lxfopv ef rnhr" {
+		t.Errorf("vigenere: %q", got)
+	}
+	// Beaufort is its own inverse
+	ct := Encrypt(pt, key, Beaufort{})
+	if got := Encrypt(ct, key, Beaufort{}); !bytes.Equal(got, pt) {
+		t.Errorf("beaufort twice = %q, want %q", got, pt)
+	}
+	// variant Beaufort encrypts as Vigenère decrypts
+	if got, want := Encrypt(pt, key, VariantBeaufort{}), Decrypt(pt, key, Vigenere{}); !bytes.Equal(got, want) {
+		t.Errorf("variant-beaufort = %q, want %q", got, want)
+	}
+}
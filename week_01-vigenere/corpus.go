@@ -0,0 +1,128 @@
+package vigenere
+
+import (
+	_ "embed"
+	"fmt"
+	"math/rand"
+)
+
+// CORPUS is a sample of English prose used to generate test ciphertexts
+//
+//go:embed corpus.txt
+var CORPUS []byte
+
+// Sample is a ciphertext generated with a random key
+type Sample struct {
+	Plaintext  []byte
+	Key        []byte
+	Ciphertext []byte
+}
+
+// Generator produces ciphertexts of random excerpts of a corpus under
+// random keys
+type Generator struct {
+	Corpus []byte     // CORPUS if nil
+	Cipher Cipher     // Xor if nil
+	Rand   *rand.Rand // required
+}
+
+// NewGenerator returns a generator with a deterministic source of
+// randomness, so that the samples can be reproduced from the seed
+func NewGenerator(corpus []byte, c Cipher, seed int64) *Generator {
+	return &Generator{Corpus: corpus, Cipher: c, Rand: rand.New(rand.NewSource(seed))}
+}
+
+// Sample encrypts an excerpt of ctLen bytes of the corpus with a random
+// key of keyLen symbols
+func (g *Generator) Sample(ctLen, keyLen int) (Sample, error) {
+	corpus := g.Corpus
+	if corpus == nil {
+		corpus = CORPUS
+	}
+	cipher := g.Cipher
+	if cipher == nil {
+		cipher = Xor{}
+	}
+	if ctLen < 1 || ctLen > len(corpus) {
+		return Sample{}, fmt.Errorf("invalid ciphertext length %d "+
+			"(the corpus has %d bytes)", ctLen, len(corpus))
+	}
+	if keyLen < 1 {
+		return Sample{}, fmt.Errorf("invalid key length %d", keyLen)
+	}
+
+	start := g.Rand.Intn(len(corpus) - ctLen + 1)
+	pt := corpus[start : start+ctLen]
+	keys := cipher.Keys()
+	key := make([]byte, keyLen)
+	for i := range key {
+		key[i] = keys[g.Rand.Intn(len(keys))]
+	}
+	return Sample{
+		Plaintext:  pt,
+		Key:        key,
+		Ciphertext: Encrypt(pt, key, cipher),
+	}, nil
+}
+
+// SuccessRate is the outcome of breaking the samples of a given
+// ciphertext and key length
+type SuccessRate struct {
+	CtLen, KeyLen int
+	Trials        int
+	KeyLenFound   int     // trials where the key length (or a multiple) was ranked first
+	Broken        int     // trials where the whole plaintext was recovered
+	ByteAccuracy  float64 // fraction of the plaintext bytes recovered
+}
+
+// Rate returns the fraction of the trials where the plaintext was recovered
+func (r SuccessRate) Rate() float64 {
+	if r.Trials == 0 {
+		return 0
+	}
+	return float64(r.Broken) / float64(r.Trials)
+}
+
+// Measure breaks trials samples for every pair of ciphertext and key
+// length, with the cipher of the generator and the options of the breaker
+// (whose range must include the key lengths)
+func Measure(g *Generator, ctLens, keyLens []int, trials int, opts Options) ([]SuccessRate, error) {
+	opts.Cipher = g.Cipher
+	var rates []SuccessRate
+	for _, ctLen := range ctLens {
+		for _, keyLen := range keyLens {
+			r := SuccessRate{CtLen: ctLen, KeyLen: keyLen, Trials: trials}
+			var correct, total int
+			for t := 0; t < trials; t++ {
+				s, err := g.Sample(ctLen, keyLen)
+				if err != nil {
+					return nil, err
+				}
+				candidates, err := BreakWith(s.Ciphertext, opts)
+				if err != nil {
+					return nil, err
+				}
+				best := candidates[0]
+				if best.KeyLen%keyLen == 0 {
+					r.KeyLenFound++
+				}
+				n := 0
+				for i := range s.Plaintext {
+					if best.Plaintext[i] == s.Plaintext[i] {
+						n++
+					}
+				}
+				if n == len(s.Plaintext) {
+					r.Broken++
+				}
+				correct += n
+				total += len(s.Plaintext)
+			}
+			if total > 0 {
+				r.ByteAccuracy = float64(correct) / float64(total)
+			}
+			rates = append(rates, r)
+		}
+	}
+	return rates, nil
+}
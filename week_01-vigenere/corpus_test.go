@@ -0,0 +1,50 @@
+package vigenere
+
+import "testing"
+
+// TestSuccessRates breaks samples of the embedded corpus and fails when the
+// rate of recovered plaintexts drops below its floor
+func TestSuccessRates(t *testing.T) {
+	const trials = 20
+	rows := []struct {
+		cipher        string
+		ctLen, keyLen int
+		minRate       float64
+	}{
+		{"xor", 100, 3, 0.6},
+		{"xor", 200, 7, 0.9},
+		{"xor", 500, 13, 0.9},
+		{"vigenere", 100, 3, 0.85},
+		{"vigenere", 200, 7, 0.85},
+		{"vigenere", 200, 13, 0.7},
+		{"vigenere", 500, 13, 0.9},
+		{"beaufort", 100, 3, 0.8},
+		{"beaufort", 200, 13, 0.9},
+		{"beaufort", 500, 13, 0.9},
+		{"variant-beaufort", 100, 3, 0.8},
+		{"variant-beaufort", 200, 13, 0.9},
+		{"variant-beaufort", 500, 13, 0.9},
+		{"autokey", 100, 3, 0.9},
+		{"autokey", 200, 13, 0.75},
+		{"autokey", 500, 7, 0.9},
+	}
+	for _, row := range rows {
+		c, err := ParseCipher(row.cipher)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := NewGenerator(nil, c, 1)
+		opts := Options{MaxKeyLen: 13, Refine: true}
+		rates, err := Measure(g, []int{row.ctLen}, []int{row.keyLen}, trials, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rate := rates[0].Rate(); rate < row.minRate {
+			t.Errorf("%s, %d bytes, key of %d: rate %.2f, want at least %.2f",
+				row.cipher, row.ctLen, row.keyLen, rate, row.minRate)
+		} else {
+			t.Logf("%s, %d bytes, key of %d: rate %.2f",
+				row.cipher, row.ctLen, row.keyLen, rate)
+		}
+	}
+}
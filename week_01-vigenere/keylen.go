@@ -0,0 +1,262 @@
+package vigenere
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+
+	"github.com/gpdionisio/umcp_cryptography/langmodel"
+)
+
+// KeyLenEstimator scores the key lengths from min to max of a ciphertext,
+// the higher the more likely. scores[i] is the score of length min+i.
+type KeyLenEstimator interface {
+	Name() string
+	Scores(ct []byte, min, max int) []float64
+}
+
+// countEstimator is implemented by the estimators that only need the
+// counters of the streams, so that they can score a ciphertext that is
+// read as a stream (see Analyzer)
+type countEstimator interface {
+	KeyLenEstimator
+	scoreCounts(counters StreamCounters) float64
+}
+
+// scoreEachLen scores every length from min to max with the counters
+// of its streams
+func scoreEachLen(e countEstimator, ct []byte, min, max int) []float64 {
+	var scores []float64
+	for L := min; L <= max; L++ {
+		scores = append(scores, e.scoreCounts(countStreams(ct, L)))
+	}
+	return scores
+}
+
+// SumOfSquares scores a length with the average sum of the frequencies
+// squared of its streams
+type SumOfSquares struct{}
+
+func (SumOfSquares) Name() string { return "sos" }
+
+func (e SumOfSquares) Scores(ct []byte, min, max int) []float64 {
+	return scoreEachLen(e, ct, min, max)
+}
+
+func (SumOfSquares) scoreCounts(counters StreamCounters) float64 {
+	freq := frequencies(counters)
+	var s = make([]float64, len(freq))
+	for i := range freq {
+		s[i] = sumArrSquared(freq[i][:])
+	}
+	return avgArr(s)
+}
+
+// IndexOfCoincidence scores a length with the average index of coincidence
+// of its streams, the probability that two bytes drawn without
+// replacement from the same stream are equal
+type IndexOfCoincidence struct{}
+
+func (IndexOfCoincidence) Name() string { return "ioc" }
+
+func (e IndexOfCoincidence) Scores(ct []byte, min, max int) []float64 {
+	return scoreEachLen(e, ct, min, max)
+}
+
+func (IndexOfCoincidence) scoreCounts(counters StreamCounters) float64 {
+	var s []float64
+	for _, blk := range counters {
+		n := sumArr(blk[:])
+		if n < 2 {
+			continue
+		}
+		var coinc uint
+		for _, c := range blk {
+			if c > 1 {
+				coinc += c * (c - 1)
+			}
+		}
+		s = append(s, float64(coinc)/float64(n*(n-1)))
+	}
+	if len(s) == 0 {
+		return 0
+	}
+	return avgArr(s)
+}
+
+// Kasiski scores a length with the fraction of the distances between
+// repeated n-grams that it divides, minus the fraction 1/L expected
+// by chance (so that the divisors of the key length do not win)
+type Kasiski struct {
+	N int // n-gram length, 3 if zero
+}
+
+func (Kasiski) Name() string { return "kasiski" }
+
+func (k Kasiski) Scores(ct []byte, min, max int) []float64 {
+	n := k.N
+	if n == 0 {
+		n = 3
+	}
+	// distances between consecutive occurrences of each n-gram
+	last := make(map[string]int)
+	var distances []int
+	for i := 0; i+n <= len(ct); i++ {
+		gram := string(ct[i : i+n])
+		if j, ok := last[gram]; ok {
+			distances = append(distances, i-j)
+		}
+		last[gram] = i
+	}
+
+	scores := make([]float64, max-min+1)
+	if len(distances) == 0 {
+		return scores
+	}
+	for L := min; L <= max; L++ {
+		var divided int
+		for _, d := range distances {
+			if d%L == 0 {
+				divided++
+			}
+		}
+		scores[L-min] = float64(divided)/float64(len(distances)) - 1/float64(L)
+	}
+	return scores
+}
+
+// HammingDistance scores a length with the opposite of the average
+// Hamming distance, in bits per byte, between consecutive blocks of that
+// length: blocks encrypted with the same key differ as much as the
+// plaintexts do
+type HammingDistance struct {
+	Blocks int // maximum number of block pairs compared, all if zero
+}
+
+func (HammingDistance) Name() string { return "hamming" }
+
+func (h HammingDistance) Scores(ct []byte, min, max int) []float64 {
+	var scores []float64
+	for L := min; L <= max; L++ {
+		var dist, pairs int
+		for i := 0; i+2*L <= len(ct); i += L {
+			if h.Blocks > 0 && pairs == h.Blocks {
+				break
+			}
+			for j := 0; j < L; j++ {
+				dist += bits.OnesCount8(ct[i+j] ^ ct[i+L+j])
+			}
+			pairs++
+		}
+		if pairs == 0 {
+			scores = append(scores, -8) // as far as possible
+			continue
+		}
+		scores = append(scores, -float64(dist)/float64(pairs*L))
+	}
+	return scores
+}
+
+// BestDecryption scores a length with the opposite of the average
+// chi-squared, per symbol, of the best decryption of its streams. Unlike
+// the others it works for ciphers whose streams are not just permuted,
+// like Autokey, and it is less biased towards the multiples of the key
+// length on short ciphertexts.
+type BestDecryption struct {
+	Cipher      Cipher              // Xor if nil
+	Frequencies *langmodel.Unigrams // langmodel.ENGLISH if nil
+}
+
+func (BestDecryption) Name() string { return "decrypt" }
+
+func (d BestDecryption) Scores(ct []byte, min, max int) []float64 {
+	cipher := d.cipher()
+	var scores []float64
+	for L := min; L <= max; L++ {
+		var s []float64
+		for _, stream := range SplitStreams(ct, L) {
+			best := RankKeys(stream, cipher, d.Frequencies, CHI_SQUARED)[0]
+			s = append(s, best.Score/float64(len(stream)))
+		}
+		scores = append(scores, -avgArr(s))
+	}
+	return scores
+}
+
+// scoreCounts only holds for the ciphers where each symbol of a stream
+// is decrypted independently of the others (not Autokey)
+func (d BestDecryption) scoreCounts(counters StreamCounters) float64 {
+	cipher := d.cipher()
+	var s []float64
+	for i := range counters {
+		n := sumArr(counters[i][:])
+		if n == 0 {
+			continue
+		}
+		best := rankCounts(&counters[i], cipher, d.Frequencies, CHI_SQUARED)[0]
+		s = append(s, best.Score/float64(n))
+	}
+	if len(s) == 0 {
+		return 0
+	}
+	return -avgArr(s)
+}
+
+func (d BestDecryption) cipher() Cipher {
+	if d.Cipher == nil {
+		return Xor{}
+	}
+	return d.Cipher
+}
+
+// Vote combines estimators with a Borda count: each length gets from
+// each estimator as many points as the lengths ranked below it
+type Vote struct {
+	Estimators []KeyLenEstimator // all the others if empty
+}
+
+func (Vote) Name() string { return "vote" }
+
+func (v Vote) Scores(ct []byte, min, max int) []float64 {
+	estimators := v.Estimators
+	if len(estimators) == 0 {
+		estimators = []KeyLenEstimator{
+			SumOfSquares{}, IndexOfCoincidence{}, Kasiski{}, HammingDistance{},
+		}
+	}
+	scores := make([]float64, max-min+1)
+	for _, e := range estimators {
+		ranking := rank(e.Scores(ct, min, max))
+		for pos, i := range ranking {
+			scores[i] += float64(len(ranking) - 1 - pos)
+		}
+	}
+	return scores
+}
+
+// rank returns the indexes of the scores, the highest score first
+// (the lowest index first on ties)
+func rank(scores []float64) []int {
+	idx := make([]int, len(scores))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return scores[idx[i]] > scores[idx[j]]
+	})
+	return idx
+}
+
+// ParseEstimator returns the estimator with the given name
+func ParseEstimator(name string) (KeyLenEstimator, error) {
+	for _, e := range []KeyLenEstimator{
+		SumOfSquares{}, IndexOfCoincidence{}, Kasiski{}, HammingDistance{},
+		BestDecryption{}, Vote{},
+	} {
+		if e.Name() == name {
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key length estimator %q "+
+		"(expected sos, ioc, kasiski, hamming, decrypt or vote)", name)
+}
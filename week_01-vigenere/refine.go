@@ -0,0 +1,123 @@
+package vigenere
+
+import (
+	"math"
+
+	"github.com/gpdionisio/umcp_cryptography/langmodel"
+)
+
+// Refinement is a key improved by Refine
+type Refinement struct {
+	Key []byte
+	// Confidence[i] is the probability of Key[i] against the other key
+	// symbols according to the model, all else being equal
+	Confidence []float64
+	Score      float64 // log-likelihood of the plaintext
+	Changed    []bool  // Changed[i] is true if Key[i] was corrected
+}
+
+// refiner holds the ciphertext split in the streams of the key, and its
+// current decryption
+type refiner struct {
+	cipher  Cipher
+	model   *langmodel.NgramModel
+	unigram *langmodel.Unigrams
+	streams [][]byte // symbols of each stream
+	pos     [][]int  // positions of the symbols of each stream in the text
+	pt      []byte
+}
+
+// score returns the log-likelihood of the plaintext around the symbols
+// of stream i: the n-grams covering them and their unigram frequencies
+func (r *refiner) score(i int) float64 {
+	score := r.model.ScoreAround(r.pt, r.pos[i])
+	for _, p := range r.pos[i] {
+		score += math.Log(r.unigram[r.pt[p]])
+	}
+	return score
+}
+
+// decrypt decrypts stream i with the key symbol k
+func (r *refiner) decrypt(i int, k byte) {
+	for j, b := range r.cipher.DecryptStream(r.streams[i], k) {
+		r.pt[r.pos[i][j]] = b
+	}
+}
+
+// Refine improves the key with hill climbing: every key symbol is in turn
+// replaced by the one maximizing the likelihood of the whole plaintext,
+// under the n-gram model (langmodel.ENGLISH_NGRAMS if nil) and the unigram
+// frequencies of opts, until no key symbol changes. This corrects the key
+// symbols whose stream alone was ambiguous, thanks to their neighbours.
+func Refine(ct, key []byte, opts Options) Refinement {
+	ref := Refinement{
+		Key:        append([]byte(nil), key...),
+		Confidence: make([]float64, len(key)),
+		Changed:    make([]bool, len(key)),
+	}
+	if len(key) == 0 {
+		return ref
+	}
+	r := &refiner{
+		cipher:  opts.cipher(),
+		model:   opts.Model,
+		unigram: opts.Frequencies,
+		streams: make([][]byte, len(key)),
+		pos:     make([][]int, len(key)),
+	}
+	if r.model == nil {
+		r.model = langmodel.ENGLISH_NGRAMS
+	}
+	if r.unigram == nil {
+		r.unigram = langmodel.ENGLISH
+	}
+	n := 0
+	for p, b := range ct {
+		if r.cipher.Symbol(b) {
+			r.streams[n%len(key)] = append(r.streams[n%len(key)], b)
+			r.pos[n%len(key)] = append(r.pos[n%len(key)], p)
+			n++
+		}
+	}
+	r.pt = Decrypt(ct, ref.Key, r.cipher)
+
+	keys := r.cipher.Keys()
+	scores := make([]float64, len(keys))
+	for pass := 0; pass < langmodel.MAX_PASSES; pass++ {
+		changed := false
+		for i := range ref.Key {
+			best, bestScore := ref.Key[i], r.score(i)
+			for _, k := range keys {
+				r.decrypt(i, k)
+				if s := r.score(i); s > bestScore {
+					best, bestScore = k, s
+				}
+			}
+			r.decrypt(i, best)
+			if best != ref.Key[i] {
+				ref.Key[i] = best
+				ref.Changed[i] = key[i] != best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	// confidence of each key symbol with the others fixed
+	for i := range ref.Key {
+		var cur float64
+		for j, k := range keys {
+			r.decrypt(i, k)
+			scores[j] = r.score(i)
+			if k == ref.Key[i] {
+				cur = scores[j]
+			}
+		}
+		r.decrypt(i, ref.Key[i])
+		ref.Confidence[i] = math.Exp(cur - langmodel.LogSumExp(scores))
+	}
+	ref.Score = r.model.Score(r.pt)
+	return ref
+}
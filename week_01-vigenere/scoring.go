@@ -0,0 +1,181 @@
+package vigenere
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/gpdionisio/umcp_cryptography/langmodel"
+)
+
+// Scoring is the statistic used to rank the key bytes of a stream
+type Scoring int
+
+const (
+	// chi-squared distance of the decrypted stream from the unigram
+	// frequencies (lower is better)
+	CHI_SQUARED Scoring = iota //nolint
+	// dot product of the frequencies of the decrypted stream with the
+	// unigram frequencies (higher is better)
+	DOT_PRODUCT //nolint
+	// the first key byte decrypting the stream to letters, spaces,
+	// commas and periods only (see TryKey)
+	CHARSET //nolint
+)
+
+func (s Scoring) String() string {
+	switch s {
+	case CHI_SQUARED:
+		return "chi2"
+	case DOT_PRODUCT:
+		return "dot"
+	case CHARSET:
+		return "charset"
+	}
+	return fmt.Sprintf("Scoring(%d)", int(s))
+}
+
+// ParseScoring returns the scoring named by String
+func ParseScoring(name string) (Scoring, error) {
+	for _, s := range []Scoring{CHI_SQUARED, DOT_PRODUCT, CHARSET} {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown scoring %q (expected chi2, dot or charset)", name)
+}
+
+// KeyByteScore is the score of a candidate key byte for a stream
+type KeyByteScore struct {
+	Key   byte
+	Score float64
+}
+
+// RankKeyBytes scores all the 256 key bytes of a stream of the XOR
+// cipher against the unigram frequencies (langmodel.ENGLISH if nil), the
+// best first.
+// With CHARSET the valid key bytes score 1 and the others 0.
+func RankKeyBytes(ctStream []byte, freq *langmodel.Unigrams, scoring Scoring) []KeyByteScore {
+	return RankKeys(ctStream, Xor{}, freq, scoring)
+}
+
+// RankKeys scores every key symbol of the cipher for a stream,
+// as RankKeyBytes
+func RankKeys(ctStream []byte, c Cipher, freq *langmodel.Unigrams, scoring Scoring) []KeyByteScore {
+	if freq == nil {
+		freq = langmodel.ENGLISH
+	}
+	n := float64(len(ctStream))
+
+	_, caseless := c.(interface{ caseless() })
+
+	keys := c.Keys()
+	ranking := make([]KeyByteScore, len(keys))
+	for i, k := range keys {
+		pt := c.DecryptStream(ctStream, k)
+		if caseless {
+			pt = bytes.ToLower(pt)
+		}
+		var score float64
+		switch scoring {
+		case CHI_SQUARED:
+			var counts BlockCounters
+			for _, b := range pt {
+				counts[b]++
+			}
+			score = chiSquared(&counts, freq)
+		case DOT_PRODUCT:
+			for _, b := range pt {
+				score += freq[b] / n
+			}
+		case CHARSET:
+			if isValidText(pt) {
+				score = 1
+			}
+		}
+		ranking[i] = KeyByteScore{Key: k, Score: score}
+	}
+	sortRanking(ranking, scoring)
+	return ranking
+}
+
+// rankCounts scores every key symbol of the cipher for a stream given
+// the counters of its symbols, as RankKeys. It only holds for the ciphers
+// where each symbol of a stream is decrypted independently of the others.
+func rankCounts(counts *BlockCounters, c Cipher, freq *langmodel.Unigrams, scoring Scoring) []KeyByteScore {
+	if freq == nil {
+		freq = langmodel.ENGLISH
+	}
+	_, caseless := c.(interface{ caseless() })
+	n := float64(sumArr(counts[:]))
+
+	keys := c.Keys()
+	ranking := make([]KeyByteScore, len(keys))
+	for i, k := range keys {
+		table := decryptTable(c, k)
+		var ptCounts BlockCounters
+		valid := true
+		for b, cnt := range counts {
+			if cnt == 0 {
+				continue
+			}
+			p := table[b]
+			if caseless && p >= 'A' && p <= 'Z' {
+				p |= 0x20
+			}
+			ptCounts[p] += cnt
+			if !isValidText([]byte{p}) {
+				valid = false
+			}
+		}
+		var score float64
+		switch scoring {
+		case CHI_SQUARED:
+			score = chiSquared(&ptCounts, freq)
+		case DOT_PRODUCT:
+			for b, cnt := range ptCounts {
+				score += float64(cnt) / n * freq[b]
+			}
+		case CHARSET:
+			if valid {
+				score = 1
+			}
+		}
+		ranking[i] = KeyByteScore{Key: k, Score: score}
+	}
+	sortRanking(ranking, scoring)
+	return ranking
+}
+
+func sortRanking(ranking []KeyByteScore, scoring Scoring) {
+	sort.SliceStable(ranking, func(i, j int) bool {
+		if scoring == CHI_SQUARED {
+			return ranking[i].Score < ranking[j].Score
+		}
+		return ranking[i].Score > ranking[j].Score
+	})
+}
+
+// chiSquared returns the chi-squared distance of the counted text from
+// the unigram frequencies
+func chiSquared(counts *BlockCounters, freq *langmodel.Unigrams) float64 {
+	n := float64(sumArr(counts[:]))
+	var chi float64
+	for b, c := range counts {
+		expected := n * freq[b]
+		d := float64(c) - expected
+		chi += d * d / expected
+	}
+	return chi
+}
+
+// isPrintable returns whether every byte of the text is printable ASCII
+// or whitespace
+func isPrintable(text []byte) bool {
+	for _, b := range text {
+		if !(b >= 0x20 && b < 0x7F || b == '\n' || b == '\r' || b == '\t') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,238 @@
+package vigenere
+
+import (
+	"fmt"
+	"io"
+)
+
+// Analyzer accumulates the counters of the streams of every key length
+// in the range of its options as the ciphertext is written to it, so that
+// a ciphertext too large to be loaded can be scored by copying it in:
+//
+//	a, err := NewAnalyzer(opts)
+//	...
+//	_, err = io.Copy(a, r)
+//	candidates, err := a.Candidates()
+//
+// It needs 2 KiB per stream, that is about max²/2 KiB for the lengths
+// up to max. The estimators that need the whole text (Kasiski, Hamming
+// distance, Vote) and the Autokey cipher are not supported.
+type Analyzer struct {
+	opts      Options
+	estimator countEstimator
+	min, max  int
+	counters  []StreamCounters // counters[L-min]
+	pos       []int            // next stream of each length
+	n         int              // number of symbols written
+	symbol    [256]bool
+	buf       []byte
+}
+
+// NewAnalyzer returns an analyzer for the key lengths, cipher and scoring
+// of opts
+func NewAnalyzer(opts Options) (*Analyzer, error) {
+	cipher := opts.cipher()
+	if _, ok := cipher.(interface{ chain(k, pt byte) byte }); ok {
+		return nil, fmt.Errorf("cipher %s cannot be analyzed as a stream", cipher.Name())
+	}
+	estimator, ok := opts.estimator().(countEstimator)
+	if !ok {
+		return nil, fmt.Errorf("key length estimator %s needs the whole ciphertext",
+			opts.estimator().Name())
+	}
+	a := &Analyzer{opts: opts, estimator: estimator, min: opts.MinKeyLen, max: opts.MaxKeyLen}
+	if a.min == 0 {
+		a.min = 1
+	}
+	if a.max == 0 {
+		a.max = MAX_KEY_LEN
+	}
+	if a.min < 1 || a.min > a.max {
+		return nil, fmt.Errorf("invalid key length range [%d, %d]",
+			opts.MinKeyLen, opts.MaxKeyLen)
+	}
+	for L := a.min; L <= a.max; L++ {
+		a.counters = append(a.counters, make(StreamCounters, L))
+	}
+	a.pos = make([]int, len(a.counters))
+	for b := range a.symbol {
+		a.symbol[b] = cipher.Symbol(byte(b))
+	}
+	return a, nil
+}
+
+// Write counts the symbols of p in the streams of every key length
+func (a *Analyzer) Write(p []byte) (int, error) {
+	syms := a.buf[:0]
+	for _, b := range p {
+		if a.symbol[b] {
+			syms = append(syms, b)
+		}
+	}
+	a.buf = syms
+	// one length at a time
+	for i, counters := range a.counters {
+		pos := a.pos[i]
+		for _, b := range syms {
+			counters[pos][b]++
+			pos++
+			if pos == len(counters) {
+				pos = 0
+			}
+		}
+		a.pos[i] = pos
+	}
+	a.n += len(syms)
+	return len(p), nil
+}
+
+// Len returns the number of symbols written so far
+func (a *Analyzer) Len() int {
+	return a.n
+}
+
+// keyLens returns the range of key lengths that can be scored, at most
+// the number of symbols written
+func (a *Analyzer) keyLens() (min, max int, err error) {
+	min, max = a.min, a.max
+	if max > a.n {
+		max = a.n
+	}
+	if min > max {
+		return 0, 0, fmt.Errorf("invalid key length range [%d, %d] "+
+			"for a ciphertext of %d symbols", a.min, a.max, a.n)
+	}
+	return min, max, nil
+}
+
+// Frequencies returns the frequencies of the streams of a key length
+func (a *Analyzer) Frequencies(L int) (StreamFrequencies, error) {
+	if L < a.min || L > a.max {
+		return nil, fmt.Errorf("key length %d out of range [%d, %d]", L, a.min, a.max)
+	}
+	return frequencies(a.counters[L-a.min]), nil
+}
+
+// RankKeyLens scores the key lengths written so far, as RankKeyLens
+func (a *Analyzer) RankKeyLens() ([]KeyLenScore, error) {
+	min, max, err := a.keyLens()
+	if err != nil {
+		return nil, err
+	}
+	scores := make([]float64, max-min+1)
+	for L := min; L <= max; L++ {
+		scores[L-min] = a.estimator.scoreCounts(a.counters[L-a.min])
+	}
+	var ranking []KeyLenScore
+	for _, i := range rank(scores) {
+		ranking = append(ranking, KeyLenScore{KeyLen: min + i, Score: scores[i]})
+	}
+	return ranking, nil
+}
+
+// RecoverKey finds every byte of a key of length keylen, as RecoverKeyWith
+func (a *Analyzer) RecoverKey(keylen int) (key []byte, found []bool, ranks [][]KeyByteScore, err error) {
+	if keylen < a.min || keylen > a.max {
+		return nil, nil, nil, fmt.Errorf("key length %d out of range [%d, %d]",
+			keylen, a.min, a.max)
+	}
+	cipher := a.opts.cipher()
+	counters := a.counters[keylen-a.min]
+	key = make([]byte, keylen)
+	found = make([]bool, keylen)
+	ranks = make([][]KeyByteScore, keylen)
+	for i := range counters {
+		ranks[i] = rankCounts(&counters[i], cipher, a.opts.Frequencies, a.opts.Scoring)
+		key[i] = ranks[i][0].Key
+		if a.opts.Scoring == CHARSET {
+			found[i] = ranks[i][0].Score > 0
+			continue
+		}
+		table := decryptTable(cipher, key[i])
+		found[i] = true
+		for b, cnt := range counters[i] {
+			if cnt > 0 && !isPrintable([]byte{table[b]}) {
+				found[i] = false
+				break
+			}
+		}
+	}
+	return key, found, ranks, nil
+}
+
+// Candidates recovers the key for each of the opts.TopN best ranked key
+// lengths, as BreakWith, without the plaintexts (see NewDecryptReader)
+func (a *Analyzer) Candidates() ([]Candidate, error) {
+	ranking, err := a.RankKeyLens()
+	if err != nil {
+		return nil, err
+	}
+	n := a.opts.TopN
+	if n <= 0 {
+		n = 1
+	}
+	if n > len(ranking) {
+		n = len(ranking)
+	}
+	candidates := make([]Candidate, n)
+	for i, r := range ranking[:n] {
+		c := &candidates[i]
+		c.KeyLenScore = r
+		c.Key, c.Found, c.Ranks, err = a.RecoverKey(r.KeyLen)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sortCandidates(candidates)
+	return candidates, nil
+}
+
+// decryptReader decrypts the ciphertext read from r
+type decryptReader struct {
+	r       io.Reader
+	cipher  Cipher
+	chained interface{ chain(k, pt byte) byte } // nil unless Autokey
+	keys    []byte                              // current key symbol of each stream
+	pos     int                                 // next stream
+	symbol  [256]bool
+	tables  [256]*[256]byte // decryption tables by key symbol
+}
+
+// NewDecryptReader returns a reader of the plaintext of the ciphertext
+// read from r, decrypted with the key as Decrypt does
+func NewDecryptReader(r io.Reader, key []byte, c Cipher) io.Reader {
+	d := &decryptReader{r: r, cipher: c, keys: make([]byte, len(key))}
+	copy(d.keys, key)
+	d.chained, _ = c.(interface{ chain(k, pt byte) byte })
+	for b := range d.symbol {
+		d.symbol[b] = c.Symbol(byte(b))
+	}
+	return d
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if len(d.keys) == 0 {
+		return n, err
+	}
+	for i, b := range p[:n] {
+		if !d.symbol[b] {
+			continue
+		}
+		k := d.keys[d.pos]
+		table := d.tables[k]
+		if table == nil {
+			table = decryptTable(d.cipher, k)
+			d.tables[k] = table
+		}
+		p[i] = table[b]
+		if d.chained != nil {
+			d.keys[d.pos] = d.chained.chain(k, p[i])
+		}
+		d.pos++
+		if d.pos == len(d.keys) {
+			d.pos = 0
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,102 @@
+package vigenere
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// chunks writes p to w in chunks of 1, 2, 3, 5, 8, ... bytes
+func chunks(t *testing.T, w io.Writer, p []byte) {
+	t.Helper()
+	a, b := 1, 2
+	for len(p) > 0 {
+		n := a
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+		a, b = b, a+b
+		if a > 64 {
+			a, b = 1, 2
+		}
+	}
+}
+
+func TestAnalyzerChunks(t *testing.T) {
+	for _, name := range []string{"xor", "vigenere", "beaufort"} {
+		c, err := ParseCipher(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := NewGenerator(nil, c, 1).Sample(600, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := Options{Cipher: c, MaxKeyLen: 13, TopN: 3}
+		want, err := BreakWith(s.Ciphertext, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a, err := NewAnalyzer(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks(t, a, s.Ciphertext)
+		got, err := a.Candidates()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: %d candidates, want %d", name, len(got), len(want))
+		}
+		for i := range got {
+			if got[i].KeyLen != want[i].KeyLen || !bytes.Equal(got[i].Key, want[i].Key) {
+				t.Errorf("%s: candidate %d has key %q of length %d, want %q of length %d",
+					name, i, got[i].Key, got[i].KeyLen, want[i].Key, want[i].KeyLen)
+			}
+		}
+
+		// the streamed plaintext under the best key is the whole one
+		key := got[0].Key
+		r := NewDecryptReader(iotest.OneByteReader(bytes.NewReader(s.Ciphertext)), key, c)
+		pt, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(pt, Decrypt(s.Ciphertext, key, c)) || !bytes.Equal(pt, s.Plaintext) {
+			t.Errorf("%s: key %q decrypts to %q, want %q", name, key, pt, s.Plaintext)
+		}
+	}
+}
+
+func TestDecryptReaderChunks(t *testing.T) {
+	for _, name := range []string{"xor", "vigenere", "beaufort", "variant-beaufort", "autokey"} {
+		c, err := ParseCipher(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := NewGenerator(nil, c, 1).Sample(300, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Decrypt(s.Ciphertext, s.Key, c)
+		for _, r := range []io.Reader{
+			iotest.OneByteReader(bytes.NewReader(s.Ciphertext)),
+			iotest.HalfReader(bytes.NewReader(s.Ciphertext)),
+		} {
+			got, err := io.ReadAll(NewDecryptReader(r, s.Key, c))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s: read %q, want %q", name, got, want)
+			}
+		}
+	}
+}
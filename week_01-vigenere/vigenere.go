@@ -1,11 +1,17 @@
-package main
+// Package vigenere breaks the Vigenere-like cipher where byte-wise XOR is
+// used instead of addition modulo 26, as well as the classic polyalphabetic
+// ciphers on letters (see Cipher).
+package vigenere
 
 import (
-	"encoding/hex"
 	"fmt"
 	"math"
+	"sort"
+
+	"github.com/gpdionisio/umcp_cryptography/langmodel"
 )
 
+// MAX_KEY_LEN is the default longest key length searched
 const MAX_KEY_LEN int = 13
 
 // number of occurrences of each char in a given block of text
@@ -49,41 +55,45 @@ func xorArr(arr []byte, k byte) []byte {
 	return res
 }
 
-// We start with an array of StreamCounters, one for every possible
-// key lenght L=1,...,MAX_KEY_LEN.
-// Then we count the occurences of each char in each stream
-// and get the frequency
-func getStreamFrequencies(ct []byte) [MAX_KEY_LEN]StreamFrequencies {
-	var vec [MAX_KEY_LEN]StreamCounters
-	for L := 1; L <= MAX_KEY_LEN; L++ {
-		vec[L-1] = make([]BlockCounters, L)
-	}
-	for i, b := range ct {
-		// increment the counter for byte 'b' in every stream
-		for L := 1; L <= MAX_KEY_LEN; L++ {
-			vec[L-1][i%L][b]++
-		}
+// GetStreamFrequencies splits the ciphertext in the L streams of a key of
+// length L, then counts the occurences of each char in each stream
+// and gets the frequency
+func GetStreamFrequencies(ct []byte, L int) StreamFrequencies {
+	return frequencies(countStreams(ct, L))
+}
 
+// countStreams counts the occurences of each char in the L streams
+func countStreams(ct []byte, L int) StreamCounters {
+	counters := make(StreamCounters, L)
+	for i, b := range ct {
+		// increment the counter for byte 'b' in its stream
+		counters[i%L][b]++
 	}
-	var freq [MAX_KEY_LEN]StreamFrequencies
-	for L := 1; L <= MAX_KEY_LEN; L++ {
-		freq[L-1] = make([]BlockFrequencies, L)
-		for i, blk := range vec[L-1] {
-			tot := sumArr(blk[:])
-			for j := 0; j < len(blk); j++ {
-				freq[L-1][i][j] = float64(vec[L-1][i][j]) / float64(tot)
-			}
-		}
+	return counters
+}
 
+func frequencies(counters StreamCounters) StreamFrequencies {
+	freq := make(StreamFrequencies, len(counters))
+	for i, blk := range counters {
+		tot := sumArr(blk[:])
+		for j := 0; j < len(blk); j++ {
+			freq[i][j] = float64(blk[j]) / float64(tot)
+		}
 	}
 	return freq
 }
 
-// valid plaintext can only be:
+// TryKey returns whether the candidate key byte decrypts the stream
+// to valid plaintext, which can only be:
 // upper- and lower-case letters, punctuation, and spaces, but no numbers
-func tryKey(ct_stream []byte, candidate_key byte) bool {
-	candidate_pt := xorArr(ct_stream, candidate_key)
-	for _, b := range candidate_pt {
+func TryKey(ct_stream []byte, candidate_key byte) bool {
+	return isValidText(xorArr(ct_stream, candidate_key))
+}
+
+// isValidText returns whether the text only has letters, spaces,
+// commas and periods
+func isValidText(text []byte) bool {
+	for _, b := range text {
 		if !(b == 0x20 || // space
 			b == 0x2C || // ,
 			b == 0x2E || // .
@@ -95,92 +105,230 @@ func tryKey(ct_stream []byte, candidate_key byte) bool {
 	return true
 }
 
-func findKey(ct_stream []byte) (byte, bool) {
+// FindKey returns the first key byte that decrypts the stream to valid
+// plaintext, if any
+func FindKey(ct_stream []byte) (byte, bool) {
 	for k := 0; k <= 255; k++ {
-		if tryKey(ct_stream, byte(k)) {
+		if TryKey(ct_stream, byte(k)) {
 			return byte(k), true
 		}
 	}
 	return 0x00, false
 }
 
-func main() {
-	hex_ciphertext := "F96DE8C227A259C87EE1DA2AED57C93FE5DA36ED4EC87EF2C63AAE5B" +
-		"9A7EFFD673BE4ACF7BE8923CAB1ECE7AF2DA3DA44FCF7AE29235A24C" +
-		"963FF0DF3CA3599A70E5DA36BF1ECE77F8DC34BE129A6CF4D126BF5B" +
-		"9A7CFEDF3EB850D37CF0C63AA2509A76FF9227A55B9A6FE3D720A850" +
-		"D97AB1DD35ED5FCE6BF0D138A84CC931B1F121B44ECE70F6C032BD56" +
-		"C33FF9D320ED5CDF7AFF9226BE5BDE3FF7DD21ED56CF71F5C036A94D" +
-		"963FF8D473A351CE3FE5DA3CB84DDB71F5C17FED51DC3FE8D732BF4D" +
-		"963FF3C727ED4AC87EF5DB27A451D47EFD9230BF47CA6BFEC12ABE4A" +
-		"DF72E29224A84CDF3FF5D720A459D47AF59232A35A9A7AE7D33FB85F" +
-		"CE7AF5923AA31EDB3FF7D33ABF52C33FF0D673A551D93FFCD33DA35B" +
-		"C831B1F43CBF1EDF67F0DF23A15B963FE5DA36ED68D378F4DC36BF5B" +
-		"9A7AFFD121B44ECE76FEDC73BE5DD27AFCD773BA5FC93FE5DA3CB859" +
-		"D26BB1C63CED5CDF3FE2D730B84CDF3FF7DD21ED5ADF7CF0D636BE1E" +
-		"DB79E5D721ED57CE3FE6D320ED57D469F4DC27A85A963FF3C727ED49" +
-		"DF3FFFDD24ED55D470E69E73AC50DE3FE5DA3ABE1EDF67F4C030A44D" +
-		"DF3FF5D73EA250C96BE3D327A84D963FE5DA32B91ED36BB1D132A31E" +
-		"D87AB1D021A255DF71B1C436BF479A7AF0C13AA14794"
-
-	ct, err := hex.DecodeString(hex_ciphertext)
-	if err != nil {
-		panic(err)
+// Options sets the range of key lengths to search, how many of the
+// best ranked lengths are tried and how the key bytes are scored
+type Options struct {
+	MinKeyLen   int             // 1 if zero
+	MaxKeyLen   int             // MAX_KEY_LEN if zero, at most the number of symbols
+	TopN        int             // 1 if zero
+	Cipher      Cipher          // Xor if nil
+	Estimator   KeyLenEstimator // SumOfSquares for Xor if nil, BestDecryption for the others
+	Scoring     Scoring
+	Frequencies *langmodel.Unigrams   // langmodel.ENGLISH if nil
+	Refine      bool                  // refine the keys of BreakWith (see Refine)
+	Model       *langmodel.NgramModel // langmodel.ENGLISH_NGRAMS if nil
+}
+
+func (opts Options) cipher() Cipher {
+	if opts.Cipher == nil {
+		return Xor{}
 	}
+	return opts.Cipher
+}
 
-	// Get the sum of the frquencies squared for each possible key len
-	freq := getStreamFrequencies(ct)
-	var sums [MAX_KEY_LEN]float64
-	for L := 1; L <= MAX_KEY_LEN; L++ {
-		var s = make([]float64, L)
-		for i := 0; i < L; i++ {
-			s[i] = sumArrSquared(freq[L-1][i][:])
+func (opts Options) estimator() KeyLenEstimator {
+	switch e := opts.Estimator.(type) {
+	case nil:
+		if _, ok := opts.cipher().(Xor); ok {
+			return SumOfSquares{}
 		}
-		sums[L-1] = avgArr(s)
+		return BestDecryption{Cipher: opts.cipher(), Frequencies: opts.Frequencies}
+	case BestDecryption:
+		if e.Cipher == nil {
+			e.Cipher = opts.cipher()
+		}
+		if e.Frequencies == nil {
+			e.Frequencies = opts.Frequencies
+		}
+		return e
+	default:
+		return e
 	}
+}
 
-	// Find best value --> candidate key len
-	var keylen uint = 0
-	var max_sum float64 = 0.0
-	for i, sum := range sums {
-		if sum > max_sum {
-			keylen = uint(i + 1)
-			max_sum = sum
-		}
+func (opts Options) keyLens(ct []byte) (min, max int, err error) {
+	min, max = opts.MinKeyLen, opts.MaxKeyLen
+	if min == 0 {
+		min = 1
+	}
+	if max == 0 {
+		max = MAX_KEY_LEN
+	}
+	if max > len(ct) {
+		max = len(ct)
+	}
+	if min < 1 || min > max {
+		return 0, 0, fmt.Errorf("invalid key length range [%d, %d] "+
+			"for a ciphertext of %d symbols", opts.MinKeyLen, opts.MaxKeyLen, len(ct))
+	}
+	return min, max, nil
+}
+
+// KeyLenScore is the score of a key length given by an estimator
+type KeyLenScore struct {
+	KeyLen int
+	Score  float64
+}
+
+// RankKeyLens scores every key length in the range of opts with its
+// estimator, the best first (the shortest first on ties).
+// Only the symbols of the cipher are scored.
+func RankKeyLens(ct []byte, opts Options) ([]KeyLenScore, error) {
+	ct = symbols(ct, opts.cipher())
+	min, max, err := opts.keyLens(ct)
+	if err != nil {
+		return nil, err
+	}
+	scores := opts.estimator().Scores(ct, min, max)
+	var ranking []KeyLenScore
+	for _, i := range rank(scores) {
+		ranking = append(ranking, KeyLenScore{KeyLen: min + i, Score: scores[i]})
 	}
+	return ranking, nil
+}
 
-	fmt.Printf("Candidate key len is %d\n", keylen)
+// FindKeyLen returns the candidate key length, the one maximizing
+// the average sum of the frequencies squared of its streams
+// (0 if the ciphertext is empty)
+func FindKeyLen(ct []byte) int {
+	ranking, err := RankKeyLens(ct, Options{})
+	if err != nil {
+		return 0
+	}
+	return ranking[0].KeyLen
+}
 
-	// Build ciphertext streams
+// SplitStreams splits the ciphertext in keylen streams, the i-th stream
+// holding the bytes encrypted with key[i]
+func SplitStreams(ct []byte, keylen int) [][]byte {
 	var ct_streams [][]byte = make([][]byte, keylen)
 	for i, b := range ct {
-		ct_streams[i%int(keylen)] = append(ct_streams[i%int(keylen)], b)
+		ct_streams[i%keylen] = append(ct_streams[i%keylen], b)
 	}
+	return ct_streams
+}
+
+// RecoverKey finds every byte of a key of length keylen, scoring the
+// streams with chi-squared against langmodel.ENGLISH.
+// found[i] is false if the best key byte at index i does not decrypt its
+// stream to printable text.
+func RecoverKey(ct []byte, keylen int) (key []byte, found []bool) {
+	key, found, _ = RecoverKeyWith(ct, keylen, Options{})
+	return key, found
+}
 
-	// Find key
-	key := make([]byte, keylen)
-	for i := 0; i < int(keylen); i++ {
-		fmt.Printf("Searching key (byte at index %d)...\n", i)
-		var found bool
-		key[i], found = findKey(ct_streams[i])
-		if !found {
-			fmt.Println("  ---> NOT FOUND!")
+// RecoverKeyWith finds every byte of a key of length keylen for the
+// cipher of opts with its scoring. ranks[i] holds all the candidates for
+// key[i], the best first. With CHARSET, found[i] is false if no valid key
+// byte was found.
+func RecoverKeyWith(ct []byte, keylen int, opts Options) (key []byte, found []bool, ranks [][]KeyByteScore) {
+	cipher := opts.cipher()
+	ct_streams := SplitStreams(symbols(ct, cipher), keylen)
+	key = make([]byte, keylen)
+	found = make([]bool, keylen)
+	ranks = make([][]KeyByteScore, keylen)
+	for i := 0; i < keylen; i++ {
+		ranks[i] = RankKeys(ct_streams[i], cipher, opts.Frequencies, opts.Scoring)
+		key[i] = ranks[i][0].Key
+		if opts.Scoring == CHARSET {
+			found[i] = ranks[i][0].Score > 0
+		} else {
+			found[i] = isPrintable(cipher.DecryptStream(ct_streams[i], key[i]))
 		}
 	}
+	return key, found, ranks
+}
 
-	// Decrypt
-	fmt.Println("Decrypting...")
-	plaintext := make([]byte, len(ct))
-	for i := 0; i < len(ct); i++ {
-		plaintext[i] = ct[i] ^ key[i%int(keylen)]
+// Candidate is the result of breaking the ciphertext with a key length
+type Candidate struct {
+	KeyLenScore
+	Key       []byte
+	Found     []bool           // see RecoverKeyWith
+	Ranks     [][]KeyByteScore // all the candidates for each key byte
+	Plaintext []byte
+
+	// with opts.Refine, the confidence of each key byte and whether it
+	// was corrected (see Refinement)
+	Confidence []float64
+	Changed    []bool
+}
+
+// Complete returns whether every byte of the key was found
+func (c *Candidate) Complete() bool {
+	for _, ok := range c.Found {
+		if !ok {
+			return false
+		}
 	}
+	return true
+}
+
+// BreakWith recovers the key for each of the opts.TopN best ranked key
+// lengths. The candidates with a complete key come first, otherwise
+// they are in the order of the ranking.
+func BreakWith(ct []byte, opts Options) ([]Candidate, error) {
+	ranking, err := RankKeyLens(ct, opts)
+	if err != nil {
+		return nil, err
+	}
+	n := opts.TopN
+	if n <= 0 {
+		n = 1
+	}
+	if n > len(ranking) {
+		n = len(ranking)
+	}
+	candidates := make([]Candidate, n)
+	for i, r := range ranking[:n] {
+		c := &candidates[i]
+		c.KeyLenScore = r
+		c.Key, c.Found, c.Ranks = RecoverKeyWith(ct, r.KeyLen, opts)
+		if opts.Refine {
+			ref := Refine(ct, c.Key, opts)
+			c.Key, c.Confidence, c.Changed = ref.Key, ref.Confidence, ref.Changed
+			c.Found = checkStreams(Decrypt(ct, c.Key, opts.cipher()), len(c.Key), opts)
+		}
+		c.Plaintext = Decrypt(ct, c.Key, opts.cipher())
+	}
+	sortCandidates(candidates)
+	return candidates, nil
+}
+
+// checkStreams returns for each stream of the plaintext whether it is
+// printable (only letters, spaces, commas and periods with CHARSET)
+func checkStreams(pt []byte, keylen int, opts Options) []bool {
+	check := isPrintable
+	if opts.Scoring == CHARSET {
+		check = isValidText
+	}
+	found := make([]bool, keylen)
+	for i, stream := range SplitStreams(symbols(pt, opts.cipher()), keylen) {
+		found[i] = check(stream)
+	}
+	return found
+}
+
+// sortCandidates puts the candidates with a complete key first
+func sortCandidates(candidates []Candidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Complete() && !candidates[j].Complete()
+	})
+}
 
-	// Result:
-	/* Cryptography is the practice and study of techniques for, among other things, secure communication in the
-	   presence of attackers. Cryptography has been used for hundreds, if not thousands, of years, but traditional
-	   cryptosystems were designed and evaluated in a fairly ad hoc manner. For example, the Vigenere encryption
-	   scheme was thought to be secure for decades after it was invented, but we now know, and this exercise
-	   demonstrates, that it can be broken very easily.
-	*/
-	fmt.Println(string(plaintext))
+// Break guesses the key length, recovers the key and decrypts the
+// ciphertext. found[i] is false as in RecoverKey.
+func Break(ct []byte) (key []byte, found []bool, pt []byte) {
+	key, found = RecoverKey(ct, FindKeyLen(ct))
+	return key, found, Decrypt(ct, key, Xor{})
 }
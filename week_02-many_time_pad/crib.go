@@ -0,0 +1,204 @@
+package manytimepad
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CribMatch is an offset of a plaintext where a crib may be: the key bytes
+// it implies decrypt the other messages to printable text
+type CribMatch struct {
+	Msg, Offset int
+	Others      [][]byte // the text of every message at the offset (nil for Msg)
+}
+
+// DragCrib slides the crib across every plaintext: if message i holds the
+// crib at offset n, the key bytes are c_i[n:] xor crib, so message j holds
+// c_i xor c_j xor crib there. The offsets where every other message is
+// printable are returned (only the part of a shorter message is checked).
+func DragCrib(ciphertexts [][]byte, crib []byte) []CribMatch {
+	var matches []CribMatch
+	for i, ci := range ciphertexts {
+		for n := 0; n+len(crib) <= len(ci); n++ {
+			m := CribMatch{Msg: i, Offset: n, Others: make([][]byte, len(ciphertexts))}
+			ok := true
+			for j, cj := range ciphertexts {
+				if j == i {
+					continue
+				}
+				var text []byte
+				for k := range crib {
+					if n+k >= len(cj) {
+						break
+					}
+					text = append(text, ci[n+k]^cj[n+k]^crib[k])
+				}
+				if !isPrintable(text) {
+					ok = false
+					break
+				}
+				m.Others[j] = text
+			}
+			if ok {
+				matches = append(matches, m)
+			}
+		}
+	}
+	return matches
+}
+
+// isPrintable returns whether every byte of the text is printable ASCII
+func isPrintable(text []byte) bool {
+	for _, b := range text {
+		if b < 0x20 || b >= 0x7F {
+			return false
+		}
+	}
+	return true
+}
+
+// Lock is a guess of the plaintext of message Msg at offset Offset
+type Lock struct {
+	Msg, Offset int
+	Text        []byte
+}
+
+// Session is a crib-dragging session: the key starts from a guess
+// (e.g. RecoverKey) and every locked guess overwrites its key bytes,
+// until it is undone
+type Session struct {
+	Ciphertexts [][]byte
+	Key         []byte
+
+	start []byte   // the key before the locks
+	locks []Lock   // the locks applied, the last one first to undo
+	saved [][]byte // the key bytes overwritten by each lock
+}
+
+// NewSession starts a session on the ciphertexts with a copy of the key
+func NewSession(ciphertexts [][]byte, key []byte) *Session {
+	return &Session{
+		Ciphertexts: ciphertexts,
+		Key:         append([]byte(nil), key...),
+		start:       append([]byte(nil), key...),
+	}
+}
+
+// Lock sets the key bytes so that message msg decrypts to text at offset
+func (s *Session) Lock(msg, offset int, text []byte) error {
+	if msg < 0 || msg >= len(s.Ciphertexts) {
+		return fmt.Errorf("no message %d (there are %d)", msg, len(s.Ciphertexts))
+	}
+	if len(text) == 0 {
+		return errors.New("nothing to lock")
+	}
+	ct := s.Ciphertexts[msg]
+	end := offset + len(text)
+	if offset < 0 || end > len(ct) || end > len(s.Key) {
+		return fmt.Errorf("%d bytes at offset %d do not fit in message %d", len(text), offset, msg)
+	}
+	s.saved = append(s.saved, append([]byte(nil), s.Key[offset:end]...))
+	s.locks = append(s.locks, Lock{Msg: msg, Offset: offset, Text: append([]byte(nil), text...)})
+	for k, b := range text {
+		FixKey(s.Key, ct, offset+k, b)
+	}
+	return nil
+}
+
+// Undo reverts the last lock, returning false if there is none
+func (s *Session) Undo() bool {
+	n := len(s.locks)
+	if n == 0 {
+		return false
+	}
+	l := s.locks[n-1]
+	copy(s.Key[l.Offset:], s.saved[n-1])
+	s.locks, s.saved = s.locks[:n-1], s.saved[:n-1]
+	return true
+}
+
+// Locks returns the locks applied, in order
+func (s *Session) Locks() []Lock {
+	return s.locks
+}
+
+// Plaintexts decrypts every ciphertext with the current key
+func (s *Session) Plaintexts() [][]byte {
+	return Decrypt(s.Key, s.Ciphertexts)
+}
+
+// Save writes the session as lines "ct <hex>" for each ciphertext,
+// "key <hex>" for the starting key and "lock <msg> <offset> <hex>" for
+// each lock, so that LoadSession can replay them (and undo them)
+func (s *Session) Save(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# many-time pad session")
+	for _, ct := range s.Ciphertexts {
+		fmt.Fprintf(bw, "ct %x\n", ct)
+	}
+	fmt.Fprintf(bw, "key %x\n", s.start)
+	for _, l := range s.locks {
+		fmt.Fprintf(bw, "lock %d %d %x\n", l.Msg, l.Offset, l.Text)
+	}
+	return bw.Flush()
+}
+
+// LoadSession reads a session written by Save
+func LoadSession(r io.Reader) (*Session, error) {
+	var cts [][]byte
+	var s *Session
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		var err error
+		switch {
+		case fields[0] == "ct" && len(fields) == 2 && s == nil:
+			var ct []byte
+			ct, err = hex.DecodeString(fields[1])
+			cts = append(cts, ct)
+		case fields[0] == "key" && len(fields) == 2 && s == nil:
+			var key []byte
+			key, err = hex.DecodeString(fields[1])
+			s = NewSession(cts, key)
+		case fields[0] == "lock" && len(fields) == 4 && s != nil:
+			err = s.lockFields(fields[1:])
+		default:
+			err = fmt.Errorf("unexpected %q", fields[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, errors.New("no key in the session")
+	}
+	return s, nil
+}
+
+// lockFields applies the lock of a line "lock <msg> <offset> <hex>"
+func (s *Session) lockFields(fields []string) error {
+	msg, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return err
+	}
+	offset, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return err
+	}
+	text, err := hex.DecodeString(fields[2])
+	if err != nil {
+		return err
+	}
+	return s.Lock(msg, offset, text)
+}
@@ -0,0 +1,155 @@
+package manytimepad
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+var cribMessages = []string{
+	"We can factor the number fifteen with quantum computers.",
+	"Euler would probably enjoy that now his theorem becomes a corner stone",
+	"The nice thing about Keeyloq is now we cryptographers can drive",
+	"The ciphertext produced by a weak encryption algorithm looks as good",
+	"You don't want to buy a set of car keys from a guy who specializes",
+}
+
+// encryptMessages xors the messages with one random keystream
+func encryptMessages(messages []string) [][]byte {
+	keystream := make([]byte, 128)
+	rand.New(rand.NewSource(1)).Read(keystream)
+	var ciphertexts [][]byte
+	for _, m := range messages {
+		ct := make([]byte, len(m))
+		for j := range m {
+			ct[j] = m[j] ^ keystream[j]
+		}
+		ciphertexts = append(ciphertexts, ct)
+	}
+	return ciphertexts
+}
+
+func TestDragCrib(t *testing.T) {
+	ciphertexts := encryptMessages(cribMessages)
+	crib := []byte("quantum")
+	offset := bytes.Index([]byte(cribMessages[0]), crib)
+
+	var found bool
+	for _, m := range DragCrib(ciphertexts, crib) {
+		if m.Msg != 0 || m.Offset != offset {
+			continue
+		}
+		found = true
+		for j, text := range m.Others {
+			if j == 0 {
+				if text != nil {
+					t.Errorf("text of the crib message: %q", text)
+				}
+				continue
+			}
+			want := cribMessages[j][offset : offset+len(crib)]
+			if string(text) != want {
+				t.Errorf("message %d at %d: %q, want %q", j, offset, text, want)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("crib %q not matched in message 0 at offset %d", crib, offset)
+	}
+}
+
+func TestSessionUndo(t *testing.T) {
+	ciphertexts := encryptMessages(cribMessages)
+	s := NewSession(ciphertexts, RecoverKey(ciphertexts))
+	key := append([]byte(nil), s.Key...)
+	plaintexts := s.Plaintexts()
+
+	if err := s.Lock(0, 0, []byte("We can")); err != nil {
+		t.Fatal(err)
+	}
+	locked := append([]byte(nil), s.Key...)
+	// a wrong guess overlapping the first lock
+	if err := s.Lock(1, 3, []byte("~~~~~~~~")); err != nil {
+		t.Fatal(err)
+	}
+	pts := s.Plaintexts()
+	if got := string(pts[1][3:11]); got != "~~~~~~~~" {
+		t.Errorf("message 1 after the locks: %q", got)
+	}
+	if got := string(pts[0][:6]); got == "We can" {
+		t.Errorf("message 0 after the locks: %q, the second lock not applied", got)
+	}
+
+	if !s.Undo() {
+		t.Fatal("Undo: no lock")
+	}
+	if !bytes.Equal(s.Key, locked) {
+		t.Errorf("key after undoing the second lock = %x, want %x", s.Key, locked)
+	}
+	if got := string(s.Plaintexts()[0][:6]); got != "We can" {
+		t.Errorf("message 0 after undoing the second lock: %q", got)
+	}
+	if !s.Undo() {
+		t.Fatal("Undo: no lock")
+	}
+	if s.Undo() {
+		t.Error("Undo: a lock left")
+	}
+	if !bytes.Equal(s.Key, key) {
+		t.Errorf("key after undoing = %x, want %x", s.Key, key)
+	}
+	if !reflect.DeepEqual(s.Plaintexts(), plaintexts) {
+		t.Error("plaintexts not restored by undoing")
+	}
+
+	for _, l := range []Lock{
+		{Msg: 5, Offset: 0, Text: []byte("x")},
+		{Msg: 0, Offset: -1, Text: []byte("x")},
+		{Msg: 0, Offset: len(ciphertexts[0]) - 1, Text: []byte("xy")},
+		{Msg: 0, Offset: 0},
+	} {
+		if err := s.Lock(l.Msg, l.Offset, l.Text); err == nil {
+			t.Errorf("Lock(%d, %d, %q): no error", l.Msg, l.Offset, l.Text)
+		}
+	}
+	if len(s.Locks()) != 0 {
+		t.Errorf("%d locks applied by failed Locks", len(s.Locks()))
+	}
+}
+
+func TestSessionSaveLoad(t *testing.T) {
+	ciphertexts := encryptMessages(cribMessages)
+	s := NewSession(ciphertexts, RecoverKey(ciphertexts))
+	start := append([]byte(nil), s.Key...)
+	if err := s.Lock(2, 4, []byte("nice")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Lock(4, 0, []byte("You don't")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSession(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Ciphertexts, s.Ciphertexts) {
+		t.Error("loaded ciphertexts differ")
+	}
+	if !bytes.Equal(loaded.Key, s.Key) {
+		t.Errorf("loaded key = %x, want %x", loaded.Key, s.Key)
+	}
+	if !reflect.DeepEqual(loaded.Locks(), s.Locks()) {
+		t.Errorf("loaded locks = %v, want %v", loaded.Locks(), s.Locks())
+	}
+	// the locks can still be undone
+	for loaded.Undo() {
+	}
+	if !bytes.Equal(loaded.Key, start) {
+		t.Errorf("loaded key after undoing = %x, want %x", loaded.Key, start)
+	}
+}
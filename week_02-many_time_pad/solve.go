@@ -0,0 +1,135 @@
+package manytimepad
+
+import (
+	"math"
+
+	"github.com/gpdionisio/umcp_cryptography/langmodel"
+)
+
+// Solve recovers the key with no manual adjustment: the key bytes found
+// by the space heuristic of RecoverKey are a starting point, then every
+// key byte is in turn set to the value maximizing the likelihood of its
+// column across all the plaintexts covering it, until no key byte changes.
+// The likelihood is the sum of the n-gram models (the English letter and
+// shape trigrams if nil) and of the unigram frequencies (English if nil).
+// Each plaintext is scored as a line of its own, so that the models see
+// its first and last characters at the start and end of a sentence.
+func Solve(ciphertexts [][]byte, models []*langmodel.NgramModel, freq *langmodel.Unigrams) []byte {
+	key := RecoverKey(ciphertexts)
+	s := newSolver(ciphertexts, key, models, freq)
+	for pass := 0; pass < langmodel.MAX_PASSES; pass++ {
+		changed := false
+		for j := range key {
+			best, bestScore := key[j], s.score(j, key[j])
+			for k := 0; k < 256; k++ {
+				if score := s.score(j, byte(k)); score > bestScore {
+					best, bestScore = byte(k), score
+				}
+			}
+			s.score(j, best)
+			if best != key[j] {
+				key[j] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	return key
+}
+
+// Coverage returns the number of ciphertexts covering each key column
+// (as long as the longest ciphertext)
+func Coverage(ciphertexts [][]byte) []int {
+	coverage := make([]int, keyLen(ciphertexts))
+	for _, ct := range ciphertexts {
+		for j := range ct {
+			coverage[j]++
+		}
+	}
+	return coverage
+}
+
+// Confidence returns for each key byte its probability against the other
+// 255 values under the likelihood of Solve (per scoring term), the rest
+// of the key being fixed, weighted by the Coverage of its column: the
+// c plaintexts covering a column count as c votes for that probability
+// against one uniform guess, so a column covered by a single ciphertext
+// is at most half sure and a column covered by none is a guess.
+func Confidence(ciphertexts [][]byte, key []byte, models []*langmodel.NgramModel, freq *langmodel.Unigrams) []float64 {
+	s := newSolver(ciphertexts, key, models, freq)
+	coverage := Coverage(ciphertexts)
+	confidence := make([]float64, len(key))
+	scores := make([]float64, 256)
+	// every byte is scored by the unigrams and the n-grams covering it,
+	// which mostly tell the same: their average is less overconfident
+	terms := 1.0
+	for _, m := range s.models {
+		terms += float64(m.N)
+	}
+	for j := range key {
+		c := 0.0
+		if j < len(coverage) {
+			c = float64(coverage[j])
+		}
+		for k := range scores {
+			scores[k] = s.score(j, byte(k))
+		}
+		s.score(j, key[j])
+		for k := range scores {
+			scores[k] /= terms
+		}
+		p := math.Exp(scores[key[j]] - langmodel.LogSumExp(scores))
+		confidence[j] = (c*p + 1.0/256) / (c + 1)
+	}
+	return confidence
+}
+
+// solver scores the key bytes of the columns of the plaintexts
+type solver struct {
+	ciphertexts [][]byte
+	models      []*langmodel.NgramModel
+	freq        *langmodel.Unigrams
+	lines       [][]byte // the plaintexts between two newlines: the j-th byte is at j+2
+}
+
+func newSolver(ciphertexts [][]byte, key []byte, models []*langmodel.NgramModel, freq *langmodel.Unigrams) *solver {
+	if models == nil {
+		models = []*langmodel.NgramModel{langmodel.ENGLISH_NGRAMS, langmodel.ENGLISH_SHAPES}
+	}
+	if freq == nil {
+		freq = langmodel.ENGLISH
+	}
+	s := &solver{ciphertexts: ciphertexts, models: models, freq: freq}
+	for _, pt := range Decrypt(key, ciphertexts) {
+		s.lines = append(s.lines, append(append([]byte("\n\n"), pt...), "\n\n"...))
+	}
+	return s
+}
+
+// score returns the score of column j with the key byte k, updating the
+// plaintexts
+func (s *solver) score(j int, k byte) float64 {
+	var score float64
+	for i, ct := range s.ciphertexts {
+		if j >= len(ct) {
+			continue
+		}
+		line := s.lines[i]
+		line[j+2] = ct[j] ^ k
+		score += math.Log(s.freq[line[j+2]])
+		for _, m := range s.models {
+			// the n-grams covering the byte
+			from, to := j+2-m.N+1, j+2+m.N
+			if from < 0 {
+				from = 0
+			}
+			if to > len(line) {
+				to = len(line)
+			}
+			score += m.Score(line[from:to])
+		}
+	}
+	return score
+}
@@ -0,0 +1,51 @@
+package manytimepad
+
+import "testing"
+
+func TestConfidenceCoverage(t *testing.T) {
+	ciphertexts := encryptMessages(cribMessages)
+	n := keyLen(ciphertexts)
+	key := make([]byte, n)
+	for i, m := range cribMessages {
+		for j := range m {
+			key[j] = ciphertexts[i][j] ^ m[j]
+		}
+	}
+
+	confidence := Confidence(ciphertexts, key, nil, nil)
+	if len(confidence) != n {
+		t.Fatalf("%d confidences for a key of %d bytes", len(confidence), n)
+	}
+	coverage := Coverage(ciphertexts)
+	sure, full := 0, 0
+	for j, c := range confidence {
+		if c <= 0 || c > 1 {
+			t.Errorf("column %d: confidence %.3f out of (0, 1]", j, c)
+		}
+		switch coverage[j] {
+		case 1:
+			if c > 0.51 {
+				t.Errorf("column %d covered by one ciphertext: confidence %.3f", j, c)
+			}
+		case len(ciphertexts):
+			full++
+			if c > 0.5 {
+				sure++
+			}
+		}
+	}
+	if full == 0 || float64(sure) < 0.8*float64(full) {
+		t.Errorf("%d of the %d fully covered columns over 0.5", sure, full)
+	}
+
+	// the same key on fewer ciphertexts is less sure
+	fewer := Confidence(ciphertexts[:2], key, nil, nil)
+	var all, two float64
+	for j := 0; j < len(cribMessages[0]); j++ {
+		all += confidence[j]
+		two += fewer[j]
+	}
+	if two >= all {
+		t.Errorf("total confidence %.2f on two ciphertexts, %.2f on all", two, all)
+	}
+}
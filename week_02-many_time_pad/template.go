@@ -0,0 +1,228 @@
+package manytimepad
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MIN_CRIB_OVERLAP is the fewest known keystream bytes a template found
+// anywhere must agree with: one byte of binary data matches by chance
+// once in 256
+const MIN_CRIB_OVERLAP = 2 //nolint
+
+// Template is known plaintext of a file format or protocol, e.g. a fixed
+// header, a JSON key or an HTTP request line
+type Template struct {
+	Msg    int    // the message holding the text, -1 for every message
+	Offset int    // the offset of the text, -1 if it may be anywhere
+	Text   []byte // binary
+}
+
+// ParseTemplate parses "msg:offset:text", where msg and offset may be '*'
+// (any message, anywhere) and text is quoted as a Go string without the
+// quotes (e.g. 0:0:\x89PNG\r\n or *:*:"user":)
+func ParseTemplate(s string) (Template, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 {
+		return Template{}, fmt.Errorf("invalid template %q (expected msg:offset:text)", s)
+	}
+	t := Template{Msg: -1, Offset: -1}
+	for i, p := range []*int{&t.Msg, &t.Offset} {
+		if parts[i] == "*" {
+			continue
+		}
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return Template{}, fmt.Errorf("invalid template %q: %q is not '*' or an index", s, parts[i])
+		}
+		*p = n
+	}
+	text, err := strconv.Unquote(`"` + escapeQuotes(parts[2]) + `"`)
+	if err != nil {
+		return Template{}, fmt.Errorf("invalid template %q: %w", s, err)
+	}
+	if text == "" {
+		return Template{}, fmt.Errorf("invalid template %q: no text", s)
+	}
+	t.Text = []byte(text)
+	return t, nil
+}
+
+// escapeQuotes escapes the double quotes of the text that are not
+// escaped already, so that it can be unquoted as a Go string
+func escapeQuotes(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			b.WriteByte('\\')
+			if i+1 < len(text) {
+				i++
+				b.WriteByte(text[i])
+			}
+		case '"':
+			b.WriteString(`\"`)
+		default:
+			b.WriteByte(text[i])
+		}
+	}
+	return b.String()
+}
+
+// messages returns the messages that may hold the template
+func (t Template) messages(ciphertexts [][]byte) []int {
+	if t.Msg >= 0 {
+		if t.Msg < len(ciphertexts) {
+			return []int{t.Msg}
+		}
+		return nil
+	}
+	msgs := make([]int, len(ciphertexts))
+	for i := range msgs {
+		msgs[i] = i
+	}
+	return msgs
+}
+
+// ApplyTemplates recovers the keystream shared by binary messages from
+// templates, making no assumption on the rest of the data. The templates
+// at a fixed offset vote for the keystream bytes they imply in each of
+// their messages, and the most voted byte of a column is known: a header
+// missing from a few messages is outvoted. Every known byte decrypts all
+// the messages, and the templates found anywhere are then slid over each
+// of their messages like DragCrib: an offset is placed where the
+// keystream it implies contradicts no known byte and either agrees with
+// at least MIN_CRIB_OVERLAP known bytes, or is the only offset of the
+// message decrypting the other messages to text (printable, tabs and line
+// breaks, as in textual protocols). The keystream is extended until no
+// template can be placed.
+func ApplyTemplates(ciphertexts [][]byte, templates []Template) (key []byte, known []bool) {
+	n := keyLen(ciphertexts)
+	key = make([]byte, n)
+	known = make([]bool, n)
+
+	votes := make([]map[byte]int, n)
+	for _, t := range templates {
+		if t.Offset < 0 {
+			continue
+		}
+		for _, m := range t.messages(ciphertexts) {
+			ct := ciphertexts[m]
+			for k, b := range t.Text {
+				j := t.Offset + k
+				if j >= len(ct) {
+					break
+				}
+				if votes[j] == nil {
+					votes[j] = make(map[byte]int)
+				}
+				votes[j][ct[j]^b]++
+			}
+		}
+	}
+	for j, tally := range votes {
+		best := -1
+		for k, v := range tally {
+			if v > best || v == best && k < key[j] {
+				key[j], best = k, v
+			}
+		}
+		known[j] = best > 0
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, t := range templates {
+			if t.Offset >= 0 {
+				continue
+			}
+			for _, m := range t.messages(ciphertexts) {
+				ct := ciphertexts[m]
+				var dragged []int
+				for off := 0; off+len(t.Text) <= len(ct); off++ {
+					switch fitTemplate(ciphertexts, key, known, m, off, t.Text) {
+					case anchored:
+						setTemplate(key, known, ct, off, t.Text)
+						changed = true
+					case printable:
+						dragged = append(dragged, off)
+					}
+				}
+				if len(dragged) == 1 &&
+					fitTemplate(ciphertexts, key, known, m, dragged[0], t.Text) != noFit {
+					setTemplate(key, known, ct, dragged[0], t.Text)
+					changed = true
+				}
+			}
+		}
+	}
+	return key, known
+}
+
+// fit tells how a template placed at an offset of a message agrees with
+// the keystream
+type fit int
+
+const (
+	noFit     fit = iota // contradicts a known byte, or adds none
+	anchored             // agrees with MIN_CRIB_OVERLAP known bytes
+	printable            // decrypts the other messages to text (see isText)
+)
+
+// fitTemplate checks text at offset off of message m against the known
+// keystream bytes and, like DragCrib, against the other messages
+func fitTemplate(ciphertexts [][]byte, key []byte, known []bool, m, off int, text []byte) fit {
+	ct := ciphertexts[m]
+	overlap, unknown := 0, 0
+	for k, b := range text {
+		j := off + k
+		switch {
+		case !known[j]:
+			unknown++
+		case key[j] == ct[j]^b:
+			overlap++
+		default:
+			return noFit
+		}
+	}
+	if unknown == 0 {
+		return noFit
+	}
+	if overlap >= MIN_CRIB_OVERLAP {
+		return anchored
+	}
+	checked := 0
+	for i, other := range ciphertexts {
+		if i == m {
+			continue
+		}
+		for k, b := range text {
+			j := off + k
+			if j >= len(other) {
+				break
+			}
+			if !isText(other[j] ^ ct[j] ^ b) {
+				return noFit
+			}
+			checked++
+		}
+	}
+	if checked == 0 {
+		return noFit
+	}
+	return printable
+}
+
+// setTemplate sets the keystream bytes implied by text at offset off of
+// the ciphertext
+func setTemplate(key []byte, known []bool, ct []byte, off int, text []byte) {
+	for k, b := range text {
+		key[off+k], known[off+k] = ct[off+k]^b, true
+	}
+}
+
+// isText returns whether the byte is printable ASCII, a tab or a line break
+func isText(b byte) bool {
+	return (b >= 0x20 && b < 0x7F) || b == '\t' || b == '\r' || b == '\n'
+}
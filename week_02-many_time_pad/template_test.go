@@ -0,0 +1,77 @@
+package manytimepad
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestParseTemplateQuotes(t *testing.T) {
+	for _, c := range []struct{ in, want string }{
+		{`*:*:"user":`, `"user":`},
+		{`*:*:\"user\":`, `"user":`},
+		{`0:3:a\\"b`, `a\"b`},
+		{`0:0:\x89PNG\r\n`, "\x89PNG\r\n"},
+	} {
+		tmpl, err := ParseTemplate(c.in)
+		if err != nil {
+			t.Errorf("ParseTemplate(%q): %v", c.in, err)
+			continue
+		}
+		if string(tmpl.Text) != c.want {
+			t.Errorf("ParseTemplate(%q) = %q, want %q", c.in, tmpl.Text, c.want)
+		}
+	}
+}
+
+// TestApplyTemplatesHTTP runs the example of the README: the request lines
+// are found at a different offset in each message
+func TestApplyTemplatesHTTP(t *testing.T) {
+	requests := []string{
+		"GET /index.html HTTP/1.1\r\nHost: www.example.com\r\n\r\n",
+		"GET /images/logo.png HTTP/1.1\r\nHost: www.example.com\r\n\r\n",
+		"GET /api/v1/users?id=42 HTTP/1.1\r\nHost: api.example.com\r\n\r\n",
+	}
+	keystream := make([]byte, 64)
+	rand.New(rand.NewSource(1)).Read(keystream)
+	var ciphertexts [][]byte
+	for _, r := range requests {
+		ct := make([]byte, len(r))
+		for j := range r {
+			ct[j] = r[j] ^ keystream[j]
+		}
+		ciphertexts = append(ciphertexts, ct)
+	}
+	var tmpls []Template
+	for _, s := range []string{`*:0:GET /`, `*:*: HTTP/1.1\r\nHost: `} {
+		tmpl, err := ParseTemplate(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpls = append(tmpls, tmpl)
+	}
+
+	key, known := ApplyTemplates(ciphertexts, tmpls)
+	n := 0
+	for j, ok := range known {
+		if !ok {
+			continue
+		}
+		n++
+		if key[j] != keystream[j] {
+			t.Errorf("key[%d] = %02x, want %02x", j, key[j], keystream[j])
+		}
+	}
+	if n <= len("GET /") {
+		t.Fatalf("%d keystream bytes known, want more than GET /", n)
+	}
+	for i, pt := range Decrypt(key, ciphertexts) {
+		line := bytes.Index([]byte(requests[i]), []byte(" HTTP/1.1"))
+		for j := line; j < line+len(" HTTP/1.1\r\nHost: "); j++ {
+			if !known[j] || pt[j] != requests[i][j] {
+				t.Errorf("message %d: byte %d not recovered", i, j)
+				break
+			}
+		}
+	}
+}
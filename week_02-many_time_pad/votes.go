@@ -0,0 +1,63 @@
+package manytimepad
+
+// MIN_VOTES is the fewest votes for a space that make a key byte known
+const MIN_VOTES = 2 //nolint
+
+// Votes tallies the evidence of spaces: a space xor a letter is a letter
+// with its case flipped, while two letters xor to a non-letter, so every
+// ciphertext c_k such that c_i[j] xor c_k[j] is a letter votes for m_i[j]
+// being a space
+type Votes struct {
+	Tally [][]int // Tally[j][i]: the votes for m_i[j] being a space
+}
+
+// CountVotes tallies the votes of every pair of ciphertexts covering each
+// column (as long as the longest ciphertext)
+func CountVotes(ciphertexts [][]byte) *Votes {
+	v := &Votes{Tally: make([][]int, keyLen(ciphertexts))}
+	for j := range v.Tally {
+		v.Tally[j] = make([]int, len(ciphertexts))
+	}
+	for i, ci := range ciphertexts {
+		for k := i + 1; k < len(ciphertexts); k++ {
+			ck := ciphertexts[k]
+			for j := 0; j < len(ci) && j < len(ck); j++ {
+				if IsAsciiAlphabetic(ci[j] ^ ck[j]) {
+					v.Tally[j][i]++
+					v.Tally[j][k]++
+				}
+			}
+		}
+	}
+	return v
+}
+
+// Best returns the ciphertext with the most votes at column j (the first
+// on ties) and its votes
+func (v *Votes) Best(j int) (msg, votes int) {
+	for i, n := range v.Tally[j] {
+		if n > votes {
+			msg, votes = i, n
+		}
+	}
+	return msg, votes
+}
+
+// Key sets each key byte from the ciphertext with the most votes at its
+// column. A key byte is known if the space has at least MIN_VOTES votes
+// and the support of most of the other ciphertexts covering the column:
+// a space is voted for by every letter, a letter only by the spaces.
+func (v *Votes) Key(ciphertexts [][]byte) (key []byte, known []bool) {
+	key = make([]byte, len(v.Tally))
+	known = make([]bool, len(v.Tally))
+	coverage := Coverage(ciphertexts)
+	for j := range key {
+		msg, votes := v.Best(j)
+		if votes < MIN_VOTES || 2*votes <= coverage[j]-1 {
+			continue
+		}
+		key[j] = ciphertexts[msg][j] ^ ' '
+		known[j] = true
+	}
+	return key, known
+}
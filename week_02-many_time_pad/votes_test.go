@@ -0,0 +1,58 @@
+package manytimepad
+
+import "testing"
+
+func TestVotesKeyUnequalLengths(t *testing.T) {
+	messages := []string{
+		"the quick brown fox jumps over the lazy dog and more",
+		"pack my box with five dozen liquor jugsxx",
+		"how vexingly quick daft zebras jump",
+		"sphinx of black quartz judge my vow",
+		"two driven jocks help fax",
+	}
+	ciphertexts := encryptMessages(messages)
+	keystream := make([]byte, len(messages[0]))
+	for j := range keystream {
+		keystream[j] = ciphertexts[0][j] ^ messages[0][j]
+	}
+
+	v := CountVotes(ciphertexts)
+	key, known := v.Key(ciphertexts)
+	if len(key) != len(messages[0]) || len(known) != len(key) {
+		t.Fatalf("key of %d bytes, mask of %d, want %d", len(key), len(known), len(messages[0]))
+	}
+	coverage := Coverage(ciphertexts)
+	few := 0 // columns with some votes, but fewer than MIN_VOTES
+	for j := range key {
+		spaces := 0
+		for _, m := range messages {
+			if j < len(m) && m[j] == ' ' {
+				spaces++
+			}
+		}
+		_, votes := v.Best(j)
+		if votes > 0 && votes < MIN_VOTES {
+			few++
+		}
+		switch {
+		case votes < MIN_VOTES && known[j]:
+			t.Errorf("column %d known with %d votes", j, votes)
+		case coverage[j] == 1 && (votes != 0 || known[j]):
+			t.Errorf("column %d covered by one ciphertext: %d votes, known %v", j, votes, known[j])
+		// with more spaces, the letters get more votes than them
+		case spaces <= 1 && known[j] && key[j] != keystream[j]:
+			t.Errorf("column %d known as %02x, want %02x", j, key[j], keystream[j])
+		case spaces == 1 && coverage[j] >= 3 && !known[j]:
+			t.Errorf("column %d with one space in %d messages unknown", j, coverage[j])
+		}
+	}
+	if few == 0 {
+		t.Error("no column with fewer than MIN_VOTES votes")
+	}
+	// past the second longest message, only the first one covers the key
+	for j := len(messages[1]); j < len(key); j++ {
+		if known[j] {
+			t.Errorf("column %d known past the other messages", j)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/gpdionisio/umcp_cryptography/week_03-padding_oracle/server"
+)
+
+func main() {
+	addr := flag.String("addr", ":49101", "address to listen on")
+	keyHex := flag.String("key", "", "AES key in hex (random if empty)")
+	msg := flag.String("encrypt", "", "print the ciphertext of this message "+
+		"under the server key before serving")
+	flag.Parse()
+
+	var key []byte
+	var err error
+	if *keyHex != "" {
+		key, err = hex.DecodeString(*keyHex)
+	} else {
+		key = make([]byte, 16)
+		_, err = rand.Read(key)
+		fmt.Printf("Generated key %X\n", key)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	s, err := server.New(key)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *msg != "" {
+		ct, err := s.Encrypt([]byte(*msg))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Ciphertext %X\n", ct)
+	}
+
+	fmt.Printf("Padding oracle listening on %s\n", *addr)
+	if err := s.ListenAndServe(*addr); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,40 @@
+package paddingoracle
+
+import (
+	"context"
+	"crypto/rand"
+)
+
+// Encrypt forges a ciphertext < IV || C_1 || ... || C_n > of the plaintext
+// (CBC-R) without the key: the plaintext is padded with PKCS #7 and the
+// ciphertext is built backwards from a random last block. Decrypting C_i
+// after a zero block with the oracle gives its intermediate state
+// D_k(C_i), so C_(i-1) = D_k(C_i) xor P_i makes C_i decrypt to P_i, and
+// the IV is the last block computed. Each block costs the queries of
+// decrypting a block of binary data (see AllGuesses).
+func (o *PaddingOracle) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	padlen := 16 - len(plaintext)%16
+	pt := make([]byte, len(plaintext)+padlen)
+	copy(pt, plaintext)
+	for i := len(plaintext); i < len(pt); i++ {
+		pt[i] = byte(padlen)
+	}
+
+	n := len(pt) / 16
+	ct := make([]byte, 16+len(pt))
+	if _, err := rand.Read(ct[n*16:]); err != nil {
+		return nil, err
+	}
+	zero := make([]byte, 16)
+	for blk := n; blk >= 1; blk-- {
+		o.logf("Encrypting block %d of %d\n", n-blk+1, n)
+		state, err := o.decryptBlkAll(ctx, zero, ct[blk*16:blk*16+16])
+		if err != nil {
+			return nil, err
+		}
+		for i := range state {
+			ct[blk*16-16+i] = state[i] ^ pt[blk*16-16+i]
+		}
+	}
+	return ct, nil
+}
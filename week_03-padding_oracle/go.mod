@@ -1,3 +0,0 @@
-module w3_assign
-
-go 1.18
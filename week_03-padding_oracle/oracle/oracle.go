@@ -1,20 +1,38 @@
 package oracle
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"net"
 	"strconv"
+	"time"
+
+	"github.com/gpdionisio/umcp_cryptography/oracles"
 )
 
 type Server struct {
-	conn net.Conn
+	conn *oracles.FramedConn
+
+	// Timeouts of the network operations
+	// (oracles.DEFAULT_TIMEOUT if not set)
+	DialTimeout, ReadTimeout, WriteTimeout time.Duration
 }
 
 // Connect establishes a connection to the server
 func (s *Server) Connect(host, port string) error {
-	var err error
-	s.conn, err = net.Dial("tcp", host+":"+port)
-	return err
+	return s.ConnectContext(context.Background(), host, port)
+}
+
+// ConnectContext establishes a connection to the server,
+// giving up when ctx is done
+func (s *Server) ConnectContext(ctx context.Context, host, port string) error {
+	conn, err := oracles.Dial(ctx, net.JoinHostPort(host, port), s.DialTimeout)
+	if err != nil {
+		return err
+	}
+	s.conn = oracles.NewFramedConn(conn)
+	return nil
 }
 
 // Disconnect drops the connection
@@ -24,14 +42,25 @@ func (s *Server) Disconnect() error {
 
 // Sends ciphertext with following packet structure
 // < num_blocks(1) || ciphertext(16*num_blocks) || null-terminator(1) >
-// Returns the replied int
-// (1 for correct padding, 0 for incorrect padding, and -1 for malformed)
+// Returns the replied int, the reply is always 2 bytes long
+// (1 for correct padding, 0 for incorrect padding, and -1 along with
+// oracles.ErrMalformed for malformed)
 func (s *Server) Send(ctext []byte) (int, error) {
+	return s.SendContext(context.Background(), ctext)
+}
+
+// SendContext is like Send but gives up when ctx is done
+func (s *Server) SendContext(ctx context.Context, ctext []byte) (int, error) {
 	lenct := len(ctext)
 	if lenct%16 != 0 {
-		return -1, fmt.Errorf("invalid ciphertext length %d "+
+		return -1, fmt.Errorf("%w: invalid ciphertext length %d "+
 			"(not multiple of block length 16.)",
-			lenct)
+			oracles.ErrMalformed, lenct)
+	}
+	if lenct/16 > 255 {
+		return -1, fmt.Errorf("%w: invalid ciphertext length %d "+
+			"(the block count must fit in one byte)",
+			oracles.ErrMalformed, lenct)
 	}
 	buf := make([]byte, lenct+2)
 	buf[0] = byte(lenct / 16)
@@ -39,20 +68,38 @@ func (s *Server) Send(ctext []byte) (int, error) {
 	buf[len(ctext)+1] = 0x00
 
 	// send data
-	_, err := s.conn.Write(buf)
+	err := s.conn.Write(ctx, buf, s.WriteTimeout)
 	if err != nil {
-		return -1, fmt.Errorf("error writing: %v", err)
+		return -1, fmt.Errorf("error writing: %w", err)
 	}
 
 	// receive response
-	resp := make([]byte, 2)
-	_, err = s.conn.Read(resp)
+	resp, err := s.conn.ReadFull(ctx, 2, s.ReadTimeout)
 	if err != nil {
-		return -1, fmt.Errorf("error reading: %v", err)
+		return -1, fmt.Errorf("error reading: %w", err)
 	}
-	res, err := strconv.Atoi(string(resp[0]))
+	res, err := strconv.Atoi(string(bytes.TrimRight(resp, "\x00")))
 	if err != nil {
 		return -1, fmt.Errorf("error converting: %v", err)
 	}
+	if res == -1 {
+		return -1, oracles.ErrMalformed
+	}
 	return res, nil
 }
+
+// ValidPadding sends the ciphertext and returns whether the server replied
+// that the padding is correct. It implements oracles.PaddingOracle.
+func (s *Server) ValidPadding(ctx context.Context, ctext []byte) (bool, error) {
+	res, err := s.SendContext(ctx, ctext)
+	if err != nil {
+		return false, err
+	}
+	switch res {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid reply %d", res)
+}
@@ -0,0 +1,84 @@
+package oracle_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gpdionisio/umcp_cryptography/week_03-padding_oracle/oracle"
+	"github.com/gpdionisio/umcp_cryptography/week_03-padding_oracle/server"
+)
+
+// slowConn writes one byte at a time
+type slowConn struct {
+	net.Conn
+}
+
+func (c slowConn) Write(b []byte) (int, error) {
+	for i := range b {
+		if _, err := c.Conn.Write(b[i : i+1]); err != nil {
+			return i, err
+		}
+	}
+	return len(b), nil
+}
+
+// slowListener accepts slowConns
+type slowListener struct {
+	net.Listener
+}
+
+func (l slowListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return slowConn{conn}, nil
+}
+
+func TestRepliesInSingleBytes(t *testing.T) {
+	s, err := server.New([]byte("YELLOW SUBMARINE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(slowListener{ln})
+	t.Cleanup(func() { s.Close() })
+	host, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := oracle.Server{ReadTimeout: 5 * time.Second}
+	if err := c.Connect(host, port); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	ctx := context.Background()
+	ct, err := s.Encrypt([]byte("one byte at a time"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the last two blocks have a valid padding, the first two do not
+	for _, q := range []struct {
+		ct   []byte
+		want int
+	}{
+		{ct[len(ct)-32:], 1},
+		{ct[:32], 0},
+		{ct[len(ct)-32:], 1},
+	} {
+		res, err := c.SendContext(ctx, q.ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != q.want {
+			t.Errorf("Send(%x) = %d, want %d", q.ct, res, q.want)
+		}
+	}
+}
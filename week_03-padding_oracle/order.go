@@ -0,0 +1,208 @@
+package paddingoracle
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/gpdionisio/umcp_cryptography/langmodel"
+)
+
+// GuessOrder is the order in which the values of a plaintext byte are
+// guessed, given the bytes of the block discovered so far (in reversed
+// order, as in DiscoverNextByte). The most likely values first cut the
+// queries to the oracle.
+type GuessOrder interface {
+	Name() string
+	// Order returns the 256 byte values, each once
+	Order(discovered []byte) []byte
+}
+
+// NumericOrder guesses from 0x00 up
+type NumericOrder struct{}
+
+func (NumericOrder) Name() string { return "numeric" }
+
+func (NumericOrder) Order(discovered []byte) []byte {
+	guesses := make([]byte, 256)
+	for i := range guesses {
+		guesses[i] = byte(i)
+	}
+	return guesses
+}
+
+// FrequencyOrder guesses the most frequent bytes of the plaintext first
+type FrequencyOrder struct {
+	Frequencies *langmodel.Unigrams // langmodel.ENGLISH if nil
+}
+
+func (FrequencyOrder) Name() string { return "freq" }
+
+func (f FrequencyOrder) Order(discovered []byte) []byte {
+	freq := f.Frequencies
+	if freq == nil {
+		freq = langmodel.ENGLISH
+	}
+	return sortGuesses(func(g byte) float64 { return freq[g] })
+}
+
+// NgramOrder guesses the most likely bytes first given the bytes that
+// follow them in the block, under the n-gram models (the English letter
+// and shape trigrams if nil) and the unigram frequencies (English if nil)
+type NgramOrder struct {
+	Models      []*langmodel.NgramModel
+	Frequencies *langmodel.Unigrams
+}
+
+func (NgramOrder) Name() string { return "ngram" }
+
+func (o NgramOrder) Order(discovered []byte) []byte {
+	models, freq := o.Models, o.Frequencies
+	if models == nil {
+		models = []*langmodel.NgramModel{langmodel.ENGLISH_NGRAMS, langmodel.ENGLISH_SHAPES}
+	}
+	if freq == nil {
+		freq = langmodel.ENGLISH
+	}
+	// the guess followed by the discovered bytes, in plaintext order
+	text := make([]byte, 1, len(discovered)+1)
+	for i := len(discovered) - 1; i >= 0; i-- {
+		text = append(text, discovered[i])
+	}
+	return sortGuesses(func(g byte) float64 {
+		text[0] = g
+		score := math.Log(freq[g])
+		for _, m := range models {
+			// the n-grams starting with the guess
+			end := m.N
+			if end > len(text) {
+				end = len(text)
+			}
+			score += m.Score(text[:end])
+		}
+		return score
+	})
+}
+
+// ContextOrder guesses first the bytes that most often preceded the byte
+// after the guess in the plaintext recovered so far: the blocks already
+// decrypted (by any worker of a Pool sharing it) and the discovered end of
+// the current block. The bytes seen fewer than twice in that context
+// follow in the order of Fallback (NgramOrder if nil). It is safe for
+// concurrent use.
+type ContextOrder struct {
+	Fallback GuessOrder
+
+	mu    sync.Mutex
+	pairs [256][256]int // pairs[b][g]: the times g preceded b
+	freq  [256]int      // the times each byte was recovered
+}
+
+func (*ContextOrder) Name() string { return "context" }
+
+func (o *ContextOrder) Order(discovered []byte) []byte {
+	fallback := o.Fallback
+	if fallback == nil {
+		fallback = NgramOrder{}
+	}
+	rank := make([]int, 256)
+	for i, g := range fallback.Order(discovered) {
+		rank[g] = i
+	}
+
+	// with nothing discovered, the most recovered bytes come first
+	o.mu.Lock()
+	seen := o.freq
+	if len(discovered) > 0 {
+		seen = o.pairs[discovered[0]]
+	}
+	o.mu.Unlock()
+	for i := 1; i < len(discovered); i++ {
+		if discovered[i-1] == discovered[0] {
+			seen[discovered[i]]++
+		}
+	}
+	return sortGuesses(func(g byte) float64 {
+		// a context seen once is as likely a coincidence
+		n := seen[g]
+		if n < 2 {
+			n = 0
+		}
+		return float64(n*256 - rank[g])
+	})
+}
+
+// Learn counts the bytes of a decrypted block and the pairs they form
+func (o *ContextOrder) Learn(blk []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i, b := range blk {
+		o.freq[b]++
+		if i > 0 {
+			o.pairs[b][blk[i-1]]++
+		}
+	}
+}
+
+// learner is a GuessOrder that learns from the blocks decrypted
+type learner interface {
+	Learn(blk []byte)
+}
+
+// learn passes a decrypted block to the order, if it learns
+func (o *PaddingOracle) learn(blk []byte) {
+	if l, ok := o.Order.(learner); ok {
+		l.Learn(blk)
+	}
+}
+
+// sortGuesses orders the byte values by decreasing score (numerically
+// on ties)
+func sortGuesses(score func(g byte) float64) []byte {
+	guesses := NumericOrder{}.Order(nil)
+	scores := make([]float64, 256)
+	for i := range scores {
+		scores[i] = score(byte(i))
+	}
+	sort.SliceStable(guesses, func(i, j int) bool {
+		return scores[guesses[i]] > scores[guesses[j]]
+	})
+	return guesses
+}
+
+// ParseGuessOrder returns the order named by Name
+func ParseGuessOrder(name string) (GuessOrder, error) {
+	for _, o := range []GuessOrder{NumericOrder{}, FrequencyOrder{}, NgramOrder{}, &ContextOrder{}} {
+		if o.Name() == name {
+			return o, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown guess order %q (expected numeric, freq, ngram or context)", name)
+}
+
+// guesses returns the order of the guesses of the next byte. Whatever the
+// order, the padding comes first where the last byte of the block tells
+// it: a block ending with 0x03 likely ends with 0x03 0x03 0x03.
+func (o *PaddingOracle) guesses(discovered []byte) []byte {
+	order := o.Order
+	if order == nil {
+		order = NumericOrder{}
+	}
+	guesses := order.Order(discovered)
+	if len(discovered) == 0 {
+		return guesses
+	}
+	pad := discovered[0]
+	if pad < 0x02 || pad > 0x10 || len(discovered) >= int(pad) {
+		return guesses
+	}
+	for i, g := range guesses {
+		if g == pad {
+			copy(guesses[1:i+1], guesses[:i])
+			guesses[0] = pad
+			break
+		}
+	}
+	return guesses
+}
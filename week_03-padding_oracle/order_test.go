@@ -0,0 +1,107 @@
+package paddingoracle
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gpdionisio/umcp_cryptography/week_03-padding_oracle/server"
+)
+
+// queries decrypts the plaintext in the given order and returns the
+// queries of each block
+func queries(t *testing.T, s *server.Server, pt []byte, order GuessOrder, all bool) []int {
+	t.Helper()
+	ct, err := s.Encrypt(pt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	po := NewPaddingOracleFrom(s)
+	po.Order, po.AllGuesses = order, all
+	if _, err := po.Decrypt(context.Background(), ct); err != nil {
+		t.Fatalf("%s order: %v", order.Name(), err)
+	}
+	nblocks := len(ct)/16 - 1
+	if len(po.BlockQueries) != nblocks {
+		t.Fatalf("%s order: %d block query counts, want %d",
+			order.Name(), len(po.BlockQueries), nblocks)
+	}
+	total := 0
+	for i, q := range po.BlockQueries {
+		if q <= 0 {
+			t.Errorf("%s order: block %d decrypted in %d queries", order.Name(), i+1, q)
+		}
+		total += q
+	}
+	if total != po.Queries {
+		t.Errorf("%s order: %d queries counted per block, %d in all", order.Name(), total, po.Queries)
+	}
+	return po.BlockQueries
+}
+
+func sum(counts []int) int {
+	n := 0
+	for _, c := range counts {
+		n += c
+	}
+	return n
+}
+
+func TestGuessOrderQueries(t *testing.T) {
+	s, err := server.New(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := []byte("The attack guesses the most likely bytes first, so that " +
+		"English text needs far fewer queries than in numeric order.")
+	numeric := sum(queries(t, s, text, NumericOrder{}, false))
+	for _, order := range []GuessOrder{FrequencyOrder{}, NgramOrder{}, &ContextOrder{}} {
+		n := sum(queries(t, s, text, order, false))
+		t.Logf("%s order: %d queries, %d in numeric order", order.Name(), n, numeric)
+		if 2*n >= numeric {
+			t.Errorf("%s order: %d queries, not half the %d of numeric order",
+				order.Name(), n, numeric)
+		}
+	}
+}
+
+func TestContextOrderLearns(t *testing.T) {
+	s, err := server.New(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the keys repeat from one record to the next
+	records := []byte(`[{"id":1,"user":"alice","role":"admin"},` +
+		`{"id":2,"user":"bob","role":"guest"},` +
+		`{"id":3,"user":"carol","role":"guest"},` +
+		`{"id":4,"user":"dave","role":"admin"}]`)
+	ngram := sum(queries(t, s, records, NgramOrder{}, true))
+	learned := sum(queries(t, s, records, &ContextOrder{}, true))
+	if learned >= ngram {
+		t.Errorf("context order: %d queries, ngram order %d", learned, ngram)
+	}
+}
+
+func TestBlockQueriesNotReused(t *testing.T) {
+	s, err := server.New(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	po := NewPaddingOracleFrom(s)
+	for i, pt := range []string{"a first message of two blocks", "then another one"} {
+		ct, err := s.Encrypt([]byte(pt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		prev := po.BlockQueries
+		saved := append([]int(nil), prev...)
+		if _, err := po.Decrypt(context.Background(), ct); err != nil {
+			t.Fatal(err)
+		}
+		for j := range saved {
+			if prev[j] != saved[j] {
+				t.Errorf("Decrypt %d overwrote the block counts of the previous one", i+1)
+				break
+			}
+		}
+	}
+}
@@ -1,53 +1,62 @@
-package main
+// Package paddingoracle decrypts AES-CBC ciphertexts with PKCS #7 padding
+// given a padding oracle.
+package paddingoracle
 
 import (
-	"encoding/hex"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 
-	"w3_assign/oracle"
+	"github.com/gpdionisio/umcp_cryptography/oracles"
+	"github.com/gpdionisio/umcp_cryptography/week_03-padding_oracle/oracle"
 )
 
-const (
-	HOST = "128.8.130.16"
-	PORT = "49101"
+type PaddingOracle struct {
+	oracle oracles.PaddingOracle
+	serv   *oracle.Server // set only when connected to a remote server
 
-	CHALLENGESTR = "9F0B13944841A832B2421B9EAF6D9836813EC9D944A5C8347A7CA69AA34D8DC0DF70E343C4000A2AE35874CE75E64C31"
-)
+	// Log receives the progress of the attack (nothing is logged if nil)
+	Log io.Writer
 
-type PaddingOracle struct {
-	serv oracle.Server
+	// AllGuesses tries all the 256 values of every byte instead of the
+	// text bytes of IsValidGuess, so that binary plaintexts are recovered:
+	// the false positives of the last byte of a block are told apart by
+	// perturbing the byte before it, and no backtracking is needed
+	AllGuesses bool
+
+	// Order is the order of the guesses of each byte (NumericOrder if nil)
+	Order GuessOrder
+
+	// Queries counts the queries to the oracle, and BlockQueries holds
+	// those of each block of the last Decrypt
+	Queries      int
+	BlockQueries []int
 }
 
-func NewPaddingOracle(host, port string) *PaddingOracle {
-	var o PaddingOracle
-	err := o.serv.Connect(HOST, PORT)
+func NewPaddingOracle(host, port string) (*PaddingOracle, error) {
+	var serv oracle.Server
+	err := serv.Connect(host, port)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	fmt.Printf("Connected to server at %s:%s\n", host, port)
-	return &o
+	return &PaddingOracle{oracle: &serv, serv: &serv}, nil
+}
+
+// NewPaddingOracleFrom runs the attack against any padding oracle
+// (e.g. an in-process server or a recorded transcript)
+func NewPaddingOracleFrom(o oracles.PaddingOracle) *PaddingOracle {
+	return &PaddingOracle{oracle: o}
 }
 
 // Query queries the server for a given chiphertext
 // returns true if response status is 1 (good padding)
 // returns false if response status is 0 (bad padding)
-func (o *PaddingOracle) Query(ct1, ct2 []byte) bool {
+func (o *PaddingOracle) Query(ctx context.Context, ct1, ct2 []byte) (bool, error) {
+	o.Queries++
 	ct := ct1
 	ct = append(ct, ct2...)
-	res, err := o.serv.Send(ct)
-	if err != nil {
-		panic(err)
-	}
-	switch res {
-	case 0:
-		return false
-	case 1:
-		return true
-	case -1:
-		panic("Malformed packet!")
-	}
-	panic(fmt.Errorf("invalid reply %d", res))
+	return o.oracle.ValidPadding(ctx, ct)
 }
 
 // IsValidGuess returns whether a certain byte is a possible plaintext byte
@@ -76,13 +85,36 @@ func IsValidGuess(b byte) bool {
 //            C_j[N-1] ^ pad ^ B_1   ]
 //
 // Submit the ciphertext D_g || C_(j+1) to check for valid padding
-// if valid --> g is the next byte. Otherwise increment it and try again.
-func (o *PaddingOracle) DiscoverNextByte(prevblk,
+// if valid --> g is the next byte. Otherwise try the next guess of
+// o.Order, the guesses before startg being skipped.
+// Errors of the oracle (including ctx being done) abort the search.
+func (o *PaddingOracle) DiscoverNextByte(ctx context.Context,
+	prevblk,
 	thisblk,
 	discovered []byte,
-	startg byte) (byte, bool) {
+	startg byte) (byte, bool, error) {
+	from := 0
+	for i, g := range o.guesses(discovered) {
+		if g == startg {
+			from = i
+			break
+		}
+	}
+	g, _, ok, err := o.discoverNextByte(ctx, prevblk, thisblk, discovered, from, o.AllGuesses)
+	return g, ok, err
+}
+
+// discoverNextByte is DiscoverNextByte starting from the guess at index
+// from of the order, and trying all the 256 values if all is set.
+// next is the index of the guess after the one found.
+func (o *PaddingOracle) discoverNextByte(ctx context.Context,
+	prevblk,
+	thisblk,
+	discovered []byte,
+	from int,
+	all bool) (g byte, next int, ok bool, err error) {
 	nextIdx := 16 - len(discovered) - 1
-	fmt.Printf("Discovering byte at index %d...\n", nextIdx)
+	o.logf("Discovering byte at index %d...\n", nextIdx)
 	pad := byte(len(discovered) + 1)
 	forgedct := make([]byte, 16)
 
@@ -93,37 +125,69 @@ func (o *PaddingOracle) DiscoverNextByte(prevblk,
 	}
 
 	// try guesses for g
-	for g := startg; g < 0x7B; g++ {
-		if !IsValidGuess(g) {
+	guesses := o.guesses(discovered)
+	for i := from; i < len(guesses); i++ {
+		g := guesses[i]
+		if !all && (g >= 0x7B || !IsValidGuess(g)) {
 			continue
 		}
-		fmt.Printf("Guessing 0x%02x\r", g)
+		o.logf("Guessing 0x%02x\r", g)
 		forgedct[nextIdx] = prevblk[nextIdx] ^ g ^ pad
-		if o.Query(forgedct, thisblk) {
-			fmt.Printf("  ---> Found 0x%02x\n", g)
-			return g, true
+		ok, err := o.Query(ctx, forgedct, thisblk)
+		if err != nil {
+			return 0x00, 0, false, err
+		}
+		if ok && all && len(discovered) == 0 {
+			ok, err = o.confirmLastByte(ctx, forgedct, thisblk)
+			if err != nil {
+				return 0x00, 0, false, err
+			}
+		}
+		if ok {
+			o.logf("  ---> Found 0x%02x\n", g)
+			return g, i + 1, true, nil
 		}
 	}
 
 	// failed to find
-	return 0x00, false
+	return 0x00, 0, false, nil
+}
+
+// confirmLastByte tells whether the valid padding of the forged block
+// ends with 0x01: the plaintext may as well end with 0x02 0x02 (or 0x03
+// 0x03 0x03, ...), but then changing the byte before the last one breaks
+// the padding
+func (o *PaddingOracle) confirmLastByte(ctx context.Context, forgedblk, thisblk []byte) (bool, error) {
+	perturbed := make([]byte, 16)
+	copy(perturbed, forgedblk)
+	perturbed[14] ^= 0xFF
+	return o.Query(ctx, perturbed, thisblk)
 }
 
 // DecryptBlk recovers all the plaintext bytes of given ciphertext block
-func (o *PaddingOracle) DecryptBlk(prevblk, thisblk []byte) ([]byte, error) {
-	fmt.Printf("Decrypt %v - %v\n", prevblk, thisblk)
+func (o *PaddingOracle) DecryptBlk(ctx context.Context, prevblk, thisblk []byte) ([]byte, error) {
+	o.logf("Decrypt %v - %v\n", prevblk, thisblk)
+	if o.AllGuesses {
+		return o.decryptBlkAll(ctx, prevblk, thisblk)
+	}
 	var pt []byte
-	startg := byte(0x00)
+	var next []int // where to resume the guesses of each byte of pt
+	from := 0
 	for {
-		if g, ok := o.DiscoverNextByte(prevblk, thisblk, pt, startg); ok {
+		g, n, ok, err := o.discoverNextByte(ctx, prevblk, thisblk, pt, from, false)
+		if err != nil {
+			return []byte{}, err
+		}
+		if ok {
 			pt = append(pt, g)
-			startg = byte(0x00)
-			fmt.Printf("  ---> Current plaintext: '%s'\n\n", string(reversed(pt)))
+			next = append(next, n)
+			from = 0
+			o.logf("  ---> Current plaintext: '%s'\n\n", string(reversed(pt)))
 		} else {
 			// remove the previous guess (if any) and retry. otherwise error
 			if len(pt) > 0 {
-				startg = pt[len(pt)-1] + 1
-				pt = pt[:len(pt)-1]
+				from = next[len(next)-1]
+				pt, next = pt[:len(pt)-1], next[:len(next)-1]
 			} else {
 				return []byte{}, errors.New("attack failed")
 			}
@@ -135,56 +199,70 @@ func (o *PaddingOracle) DecryptBlk(prevblk, thisblk []byte) ([]byte, error) {
 	return reversed(pt), nil
 }
 
+// decryptBlkAll recovers the plaintext bytes of the block trying all the
+// 256 values of every byte: every byte has a single valid guess, so there
+// is nothing to backtrack
+func (o *PaddingOracle) decryptBlkAll(ctx context.Context, prevblk, thisblk []byte) ([]byte, error) {
+	var pt []byte
+	for len(pt) < 16 {
+		g, _, ok, err := o.discoverNextByte(ctx, prevblk, thisblk, pt, 0, true)
+		if err != nil {
+			return []byte{}, err
+		}
+		if !ok {
+			return []byte{}, fmt.Errorf("attack failed: no valid padding for the byte at index %d", 15-len(pt))
+		}
+		pt = append(pt, g)
+		o.logf("  ---> Current plaintext: %q\n\n", reversed(pt))
+	}
+	return reversed(pt), nil
+}
+
 // Decrypt tries to decrypt the given ciphertext with a padding oracle attack
-func (o *PaddingOracle) Decrypt(ct []byte) ([]byte, error) {
+func (o *PaddingOracle) Decrypt(ctx context.Context, ct []byte) ([]byte, error) {
 	// check len
 	if len(ct)%16 != 0 {
 		return []byte{}, fmt.Errorf("invalid ciphertext length %d "+
 			"(not multiple of block length 16.)",
 			len(ct))
 	}
+	if len(ct) < 32 {
+		return []byte{}, fmt.Errorf("invalid ciphertext length %d "+
+			"(at least an IV and a block of 16 bytes each.)",
+			len(ct))
+	}
 
 	// split in blocks
 	pt := make([]byte, len(ct)-16)
 
 	// decrypt
+	o.BlockQueries = make([]int, 0, len(ct)/16-1)
 	for blk := 1; blk < (len(ct) / 16); blk++ {
-		ptblk, err := o.DecryptBlk(ct[blk*16-16:blk*16], ct[blk*16:blk*16+16])
+		queries := o.Queries
+		ptblk, err := o.DecryptBlk(ctx, ct[blk*16-16:blk*16], ct[blk*16:blk*16+16])
 		if err != nil {
 			return []byte{}, err
 		}
 		copy(pt[blk*16-16:blk*16], ptblk)
+		o.learn(ptblk)
+		o.BlockQueries = append(o.BlockQueries, o.Queries-queries)
+		o.logf("Block %d decrypted in %d queries\n", blk, o.Queries-queries)
 	}
 	return pt, nil
 }
 
 // Disconnect closes the connection to the padding oracle
-func (o *PaddingOracle) Disconnect() {
-	err := o.serv.Disconnect()
-	if err != nil {
-		panic(err)
+func (o *PaddingOracle) Disconnect() error {
+	if o.serv == nil {
+		return nil
 	}
-	fmt.Println("Disconnected from padding oracle")
+	return o.serv.Disconnect()
 }
 
-func main() {
-	// Decode ciphertext string to bytes
-	ct, err := hex.DecodeString(CHALLENGESTR)
-	if err != nil {
-		panic(err)
-	}
-
-	// Connect to Oracle server
-	po := NewPaddingOracle(HOST, PORT)
-	defer po.Disconnect()
-
-	// try to decrypt ciphertext
-	pt, err := po.Decrypt(ct)
-	if err != nil {
-		panic(err)
+func (o *PaddingOracle) logf(format string, args ...interface{}) {
+	if o.Log != nil {
+		fmt.Fprintf(o.Log, format, args...)
 	}
-	// Yay! You get an A. =)
-	fmt.Printf("Result: %s\n", string(pt))
 }
 
 func reversed(arr []byte) []byte {
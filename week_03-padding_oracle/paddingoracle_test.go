@@ -0,0 +1,243 @@
+package paddingoracle
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"math/rand"
+	"net"
+	"testing"
+
+	"github.com/gpdionisio/umcp_cryptography/oracles"
+	"github.com/gpdionisio/umcp_cryptography/week_03-padding_oracle/oracle"
+	"github.com/gpdionisio/umcp_cryptography/week_03-padding_oracle/server"
+)
+
+var testKey = []byte("YELLOW SUBMARINE")
+
+// startServer serves a padding oracle with testKey on a free port of
+// localhost until the end of the test
+func startServer(t *testing.T) (*server.Server, string, string) {
+	t.Helper()
+	s, err := server.New(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+	t.Cleanup(func() { s.Close() })
+	host, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, host, port
+}
+
+// unpad strips the PKCS #7 padding of a plaintext
+func unpad(t *testing.T, pt []byte) []byte {
+	t.Helper()
+	if len(pt) == 0 {
+		t.Fatal("empty plaintext")
+	}
+	n := int(pt[len(pt)-1])
+	if n < 1 || n > 16 || n > len(pt) {
+		t.Fatalf("invalid padding in %x", pt)
+	}
+	return pt[:len(pt)-n]
+}
+
+func TestDecryptOverTCP(t *testing.T) {
+	s, host, port := startServer(t)
+	want := []byte("Attack at dawn, the bridge is the target.")
+	ct, err := s.Encrypt(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	po, err := NewPaddingOracle(host, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer po.Disconnect()
+	pt, err := po.Decrypt(context.Background(), ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := unpad(t, pt); !bytes.Equal(got, want) {
+		t.Errorf("Decrypt = %q, want %q", got, want)
+	}
+}
+
+func TestDecryptReplay(t *testing.T) {
+	s, err := server.New(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte("Recorded once, replayed offline")
+	ct, err := s.Encrypt(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	rec := &oracles.Recorder{Padding: s}
+	if _, err := rec.ValidPadding(ctx, ct[:17]); !errors.Is(err, oracles.ErrMalformed) {
+		t.Fatalf("ValidPadding(17 bytes): got %v, want %v", err, oracles.ErrMalformed)
+	}
+	recorded, err := NewPaddingOracleFrom(rec).Decrypt(ctx, ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := oracles.NewReplayer(rec.Exchanges())
+	if _, err := r.ValidPadding(ctx, ct[:17]); !errors.Is(err, oracles.ErrMalformed) {
+		t.Errorf("replayed ValidPadding(17 bytes): got %v, want %v", err, oracles.ErrMalformed)
+	}
+	pt, err := NewPaddingOracleFrom(r).Decrypt(ctx, ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pt, recorded) {
+		t.Errorf("replayed Decrypt = %q, recorded %q", pt, recorded)
+	}
+	if got := unpad(t, pt); !bytes.Equal(got, want) {
+		t.Errorf("replayed Decrypt = %q, want %q", got, want)
+	}
+}
+
+func TestDecryptAllGuesses(t *testing.T) {
+	s, err := server.New(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary := make([]byte, 40)
+	rand.New(rand.NewSource(1)).Read(binary)
+	plaintexts := []struct {
+		name string
+		pt   []byte
+	}{
+		{"binary", binary},
+		{"braces", []byte("{~}|{~}|\x7f{{~~}}||`^_{~}\x7f~{|}~{|")},
+		// inner blocks whose last bytes are also a valid padding
+		{"02 02", []byte("ends in two 2s\x02\x02and then text")},
+		{"03 03 03", []byte("ends in 3 3s\xff\x03\x03\x03then more text")},
+		{"03 03", []byte("ends in two 3s\x03\x03and then text")},
+	}
+	for _, name := range []string{"numeric", "freq", "ngram", "context"} {
+		order, err := ParseGuessOrder(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range plaintexts {
+			ct, err := s.Encrypt(p.pt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			po := NewPaddingOracleFrom(s)
+			po.AllGuesses, po.Order = true, order
+			pt, err := po.Decrypt(context.Background(), ct)
+			if err != nil {
+				t.Errorf("%s order, %s: %v", name, p.name, err)
+				continue
+			}
+			if got := unpad(t, pt); !bytes.Equal(got, p.pt) {
+				t.Errorf("%s order, %s: Decrypt = %q, want %q", name, p.name, got, p.pt)
+			}
+		}
+	}
+}
+
+func TestDecryptShortCiphertext(t *testing.T) {
+	s, err := server.New(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, n := range []int{0, 15, 16, 17, 31} {
+		if _, err := NewPaddingOracleFrom(s).Decrypt(ctx, make([]byte, n)); err == nil {
+			t.Errorf("Decrypt(%d bytes): no error", n)
+		}
+		pool := NewPool(NewPaddingOracleFrom(s), NewPaddingOracleFrom(s))
+		if _, err := pool.Decrypt(ctx, make([]byte, n)); err == nil {
+			t.Errorf("Pool.Decrypt(%d bytes): no error", n)
+		}
+	}
+}
+
+func TestMaxBlocks(t *testing.T) {
+	s, host, port := startServer(t)
+	c := &oracle.Server{}
+	if err := c.Connect(host, port); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	ctx := context.Background()
+	for nblocks := 0; nblocks <= server.MAX_BLOCKS+1; nblocks++ {
+		ct := make([]byte, nblocks*16)
+		want := nblocks < 1 || nblocks > server.MAX_BLOCKS
+		if _, err := s.ValidPadding(ctx, ct); errors.Is(err, oracles.ErrMalformed) != want {
+			t.Errorf("in-process ValidPadding(%d blocks): got %v", nblocks, err)
+		}
+		if _, err := c.ValidPadding(ctx, ct); errors.Is(err, oracles.ErrMalformed) != want {
+			t.Errorf("TCP ValidPadding(%d blocks): got %v", nblocks, err)
+		}
+	}
+	if _, err := c.SendContext(ctx, make([]byte, 256*16)); !errors.Is(err, oracles.ErrMalformed) {
+		t.Errorf("SendContext(256 blocks): got %v, want %v", err, oracles.ErrMalformed)
+	}
+}
+
+// decryptCBC decrypts the ciphertext (IV first) with testKey
+func decryptCBC(t *testing.T, ct []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt := make([]byte, len(ct)-16)
+	cipher.NewCBCDecrypter(block, ct[:16]).CryptBlocks(pt, ct[16:])
+	return pt
+}
+
+func TestEncryptOverTCP(t *testing.T) {
+	s, host, port := startServer(t)
+	po, err := NewPaddingOracle(host, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer po.Disconnect()
+	po.Order = NgramOrder{}
+
+	ctx := context.Background()
+	text := []byte("Forged without the key, block after block.")
+	for _, n := range []int{0, 15, 16, 33} {
+		want := text[:n]
+		ct, err := po.Encrypt(ctx, want)
+		if err != nil {
+			t.Fatalf("Encrypt(%d bytes): %v", n, err)
+		}
+		if len(ct) != 16+(n/16+1)*16 {
+			t.Errorf("Encrypt(%d bytes): %d bytes of ciphertext", n, len(ct))
+		}
+		if s.Decrypt(ct) != 1 {
+			t.Errorf("Encrypt(%d bytes): invalid padding", n)
+		}
+		if got := unpad(t, decryptCBC(t, ct)); !bytes.Equal(got, want) {
+			t.Errorf("Encrypt(%d bytes) decrypts to %q, want %q", n, got, want)
+		}
+		// and the attack decrypts it back
+		pt, err := po.Decrypt(ctx, ct)
+		if err != nil {
+			t.Fatalf("Decrypt(Encrypt(%d bytes)): %v", n, err)
+		}
+		if got := unpad(t, pt); !bytes.Equal(got, want) {
+			t.Errorf("Decrypt(Encrypt(%d bytes)) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package paddingoracle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Pool decrypts the blocks of a ciphertext concurrently: every pair
+// (C_j, C_(j+1)) is attacked independently of the others, so each worker
+// decrypts one block at a time with its own oracle (e.g. its own
+// connection to the server)
+type Pool struct {
+	// Workers are the oracles of the workers, to be configured before
+	// Decrypt (e.g. AllGuesses)
+	Workers []*PaddingOracle
+
+	// Progress is called after each block with the number of blocks
+	// decrypted so far and their total, one call at a time (nothing is
+	// reported if nil)
+	Progress func(done, total int)
+
+	// BlockQueries holds the queries of each block of the last Decrypt
+	BlockQueries []int
+}
+
+// NewPool runs one worker per oracle
+func NewPool(workers ...*PaddingOracle) *Pool {
+	return &Pool{Workers: workers}
+}
+
+// DialPool connects n workers to the server, each with its own connection
+func DialPool(host, port string, n int) (*Pool, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid number of workers %d", n)
+	}
+	p := &Pool{}
+	for i := 0; i < n; i++ {
+		po, err := NewPaddingOracle(host, port)
+		if err != nil {
+			p.Disconnect()
+			return nil, fmt.Errorf("connecting worker %d: %w", i, err)
+		}
+		p.Workers = append(p.Workers, po)
+	}
+	return p, nil
+}
+
+// Decrypt decrypts the ciphertext like PaddingOracle.Decrypt, the blocks
+// being shared among the workers. The first error stops all the workers.
+func (p *Pool) Decrypt(ctx context.Context, ct []byte) ([]byte, error) {
+	if len(ct)%16 != 0 {
+		return []byte{}, fmt.Errorf("invalid ciphertext length %d "+
+			"(not multiple of block length 16.)",
+			len(ct))
+	}
+	if len(ct) < 32 {
+		return []byte{}, fmt.Errorf("invalid ciphertext length %d "+
+			"(at least an IV and a block of 16 bytes each.)",
+			len(ct))
+	}
+	if len(p.Workers) == 0 {
+		return []byte{}, errors.New("no worker in the pool")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	total := len(ct)/16 - 1
+	pt := make([]byte, len(ct)-16)
+	p.BlockQueries = make([]int, total)
+	blocks := make(chan int)
+	go func() {
+		defer close(blocks)
+		for blk := 1; blk <= total; blk++ {
+			select {
+			case blocks <- blk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		done     int
+		firstErr error
+	)
+	for _, w := range p.Workers {
+		wg.Add(1)
+		go func(w *PaddingOracle) {
+			defer wg.Done()
+			for blk := range blocks {
+				queries := w.Queries
+				ptblk, err := w.DecryptBlk(ctx, ct[blk*16-16:blk*16], ct[blk*16:blk*16+16])
+				mu.Lock()
+				p.BlockQueries[blk-1] = w.Queries - queries
+				if err != nil {
+					if firstErr == nil {
+						firstErr = fmt.Errorf("block %d: %w", blk, err)
+					}
+					mu.Unlock()
+					cancel()
+					return
+				}
+				// each block has its own place in the plaintext
+				copy(pt[blk*16-16:blk*16], ptblk)
+				w.learn(ptblk)
+				done++
+				if p.Progress != nil {
+					p.Progress(done, total)
+				}
+				mu.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return []byte{}, firstErr
+	}
+	// done before every block was handed out
+	if err := ctx.Err(); err != nil {
+		return []byte{}, err
+	}
+	return pt, nil
+}
+
+// Disconnect closes the connections of all the workers
+func (p *Pool) Disconnect() error {
+	var firstErr error
+	for _, w := range p.Workers {
+		if err := w.Disconnect(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
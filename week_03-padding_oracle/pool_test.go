@@ -0,0 +1,130 @@
+package paddingoracle
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gpdionisio/umcp_cryptography/oracles"
+	"github.com/gpdionisio/umcp_cryptography/week_03-padding_oracle/server"
+)
+
+func TestPoolDecrypt(t *testing.T) {
+	s, err := server.New(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte("Six blocks of plaintext shared among three workers, " +
+		"each one decrypting its own blocks.")
+	ct, err := s.Encrypt(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every worker holds its first query until all of them have one, so
+	// the test only ends if the blocks are decrypted concurrently
+	const nworkers = 3
+	var started sync.WaitGroup
+	started.Add(nworkers)
+	var workers []*PaddingOracle
+	for i := 0; i < nworkers; i++ {
+		var once sync.Once
+		workers = append(workers, NewPaddingOracleFrom(oracles.PaddingOracleFunc(
+			func(ctx context.Context, ct []byte) (bool, error) {
+				once.Do(func() {
+					started.Done()
+					started.Wait()
+				})
+				return s.ValidPadding(ctx, ct)
+			})))
+	}
+	// the order learns from the blocks of all the workers
+	order := &ContextOrder{}
+	for _, w := range workers {
+		w.Order = order
+	}
+	pool := NewPool(workers...)
+	var done []int
+	pool.Progress = func(n, total int) { done = append(done, n) }
+
+	pt, err := pool.Decrypt(context.Background(), ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := unpad(t, pt); !bytes.Equal(got, want) {
+		t.Errorf("Decrypt = %q, want %q", got, want)
+	}
+	nblocks := len(ct)/16 - 1
+	if len(done) != nblocks || done[nblocks-1] != nblocks {
+		t.Errorf("progress %v, want 1 to %d", done, nblocks)
+	}
+	if len(pool.BlockQueries) != nblocks {
+		t.Fatalf("%d block query counts, want %d", len(pool.BlockQueries), nblocks)
+	}
+	total := 0
+	for i, q := range pool.BlockQueries {
+		if q == 0 {
+			t.Errorf("block %d: no query counted", i+1)
+		}
+		total += q
+	}
+	queries := 0
+	for _, w := range workers {
+		queries += w.Queries
+	}
+	if total != queries {
+		t.Errorf("%d queries counted per block, %d by the workers", total, queries)
+	}
+}
+
+func TestPoolFirstErrorCancels(t *testing.T) {
+	s, err := server.New(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := s.Encrypt(make([]byte, 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// two workers hang on a query until they are cancelled, the third
+	// one fails once they are both waiting
+	errOracle := errors.New("oracle failure")
+	var waiting sync.WaitGroup
+	waiting.Add(2)
+	cancelled := make(chan error, 2)
+	hang := oracles.PaddingOracleFunc(func(ctx context.Context, ct []byte) (bool, error) {
+		waiting.Done()
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return false, ctx.Err()
+	})
+	fail := oracles.PaddingOracleFunc(func(ctx context.Context, ct []byte) (bool, error) {
+		waiting.Wait()
+		return false, errOracle
+	})
+	pool := NewPool(NewPaddingOracleFrom(hang), NewPaddingOracleFrom(hang),
+		NewPaddingOracleFrom(fail))
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := pool.Decrypt(context.Background(), ct)
+		errs <- err
+	}()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, errOracle) {
+			t.Errorf("got %v, want %v", err, errOracle)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the other workers were not cancelled")
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-cancelled; !errors.Is(err, context.Canceled) {
+			t.Errorf("worker stopped by %v, want %v", err, context.Canceled)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/gpdionisio/umcp_cryptography/oracles"
+)
+
+const (
+	BLOCK_LEN  = 16 //nolint
+	MAX_BLOCKS = 3  //nolint
+)
+
+// Server is a local replacement of proj3's server.c: it decrypts the
+// ciphertexts it receives with AES in CBC-mode and only replies whether
+// the PKCS #7 padding was correct
+type Server struct {
+	block cipher.Block
+
+	mu    sync.Mutex
+	ln    net.Listener
+	conns map[net.Conn]struct{}
+}
+
+// New creates a padding oracle server for the given AES key
+// (16, 24 or 32 bytes)
+func New(key []byte) (*Server, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{block: block, conns: make(map[net.Conn]struct{})}, nil
+}
+
+// Encrypt encrypts a message under the server key, the same way
+// cbc-encrypt.c does. The returned ciphertext is < IV || C_1 || ... || C_n >
+// with a random IV.
+func (s *Server) Encrypt(pt []byte) ([]byte, error) {
+	padlen := BLOCK_LEN - len(pt)%BLOCK_LEN
+	ct := make([]byte, BLOCK_LEN+len(pt)+padlen)
+	if _, err := io.ReadFull(rand.Reader, ct[:BLOCK_LEN]); err != nil {
+		return nil, err
+	}
+	copy(ct[BLOCK_LEN:], pt)
+	for i := len(ct) - padlen; i < len(ct); i++ {
+		ct[i] = byte(padlen)
+	}
+	mode := cipher.NewCBCEncrypter(s.block, ct[:BLOCK_LEN])
+	mode.CryptBlocks(ct[BLOCK_LEN:], ct[BLOCK_LEN:])
+	return ct, nil
+}
+
+// Decrypt decrypts the ciphertext (first block is the IV) as cbc-decrypt.c
+// does and returns 1 if the padding is correct, 0 otherwise
+func (s *Server) Decrypt(ct []byte) int {
+	if len(ct)%BLOCK_LEN != 0 || len(ct) < 2*BLOCK_LEN {
+		return 0
+	}
+	// only the last block matters for the padding
+	last := make([]byte, BLOCK_LEN)
+	s.block.Decrypt(last, ct[len(ct)-BLOCK_LEN:])
+	prev := ct[len(ct)-2*BLOCK_LEN : len(ct)-BLOCK_LEN]
+	for i := range last {
+		last[i] ^= prev[i]
+	}
+
+	pad := int(last[BLOCK_LEN-1])
+	if pad == 0 || pad > BLOCK_LEN {
+		return 0
+	}
+	for i := BLOCK_LEN - 2; i >= BLOCK_LEN-pad; i-- {
+		if int(last[i]) != pad {
+			return 0
+		}
+	}
+	return 1
+}
+
+// ValidPadding decrypts the ciphertext in-process and returns whether the
+// padding is correct. It implements oracles.PaddingOracle.
+func (s *Server) ValidPadding(ctx context.Context, ct []byte) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if err := checkLength(len(ct)); err != nil {
+		return false, err
+	}
+	return s.Decrypt(ct) == 1, nil
+}
+
+// checkLength reports with oracles.ErrMalformed a ciphertext length that
+// server.c would refuse: not a multiple of the block length, or not
+// between 1 and MAX_BLOCKS blocks
+func checkLength(n int) error {
+	if n%BLOCK_LEN != 0 {
+		return fmt.Errorf("%w: invalid ciphertext length %d "+
+			"(not multiple of block length 16.)",
+			oracles.ErrMalformed, n)
+	}
+	if n < BLOCK_LEN || n > MAX_BLOCKS*BLOCK_LEN {
+		return fmt.Errorf("%w: invalid ciphertext length %d "+
+			"(this oracle takes 1 to %d blocks)",
+			oracles.ErrMalformed, n, MAX_BLOCKS)
+	}
+	return nil
+}
+
+// ListenAndServe listens on the TCP address addr and serves the clients
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts connections on the listener and handles every client
+// in its own goroutine. It returns when the listener is closed.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+// Addr returns the address the server is listening on (nil if not serving)
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ln == nil {
+		return nil
+	}
+	return s.ln.Addr()
+}
+
+// Close stops the listener and drops all the clients
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	if s.ln != nil {
+		err = s.ln.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+		delete(s.conns, conn)
+	}
+	return err
+}
+
+// handle reads packets with the following structure until the client
+// disconnects
+// < num_blocks(1) || ciphertext(16*num_blocks) || null-terminator(1) >
+// and replies "1" for correct padding, "0" for incorrect padding
+// and "-1" for a malformed packet (always 2 bytes, as server.c does)
+func (s *Server) handle(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		nblocks, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		buf := make([]byte, int(nblocks)*BLOCK_LEN+1)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return
+		}
+
+		rc := -1
+		if checkLength(len(buf)-1) == nil && buf[len(buf)-1] == 0x00 {
+			rc = s.Decrypt(buf[:len(buf)-1])
+		}
+
+		resp := make([]byte, 2)
+		copy(resp, strconv.Itoa(rc))
+		if _, err := conn.Write(resp); err != nil {
+			return
+		}
+	}
+}
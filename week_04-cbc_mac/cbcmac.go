@@ -1,93 +1,96 @@
-package main
+// Package cbcmac forges basic CBC-MAC tags on long messages given a tag
+// oracle for 2-block messages.
+package cbcmac
 
 import (
+	"context"
 	"fmt"
-	"w4_assign/oracle"
+
+	"github.com/gpdionisio/umcp_cryptography/oracles"
+	"github.com/gpdionisio/umcp_cryptography/week_04-cbc_mac/oracle"
 )
 
 const (
-	HOST = "128.8.130.16" //nolint
-	MAC_ORACLE_PORT = "49102" //nolint
-	VRFY_ORACLE_PORT = "49103" //nolint
-
-	CHALLENGETAG = "I, the server, hereby agree that I will pay $100 to this student"
+	BLOCK_LEN   = 16 //nolint
+	MAC_MSG_LEN = 32 //nolint
 )
 
 type MacOracle struct {
-	oracle.Server
+	mac  oracles.MACOracle
+	vrfy oracles.VerifyOracle
+	serv *oracle.Server // set only when connected to a remote server
 }
 
-func NewMacOracle(host, macPort, vrfyPort string) *MacOracle {
-	var o MacOracle
-	err := o.Connect(host, macPort, vrfyPort)
+func NewMacOracle(host, macPort, vrfyPort string) (*MacOracle, error) {
+	var serv oracle.Server
+	err := serv.Connect(host, macPort, vrfyPort)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	fmt.Printf("Connected to server at %s:[%s/%s]\n",
-		host, macPort, vrfyPort)
-	return &o
+	return &MacOracle{mac: &serv, vrfy: &serv, serv: &serv}, nil
 }
 
-func (o *MacOracle) Disconnect() {
-	err := o.Server.Disconnect()
-	if err != nil {
-		panic(err)
-	}
-	fmt.Println("Disconnected from mac oracle")
+// NewMacOracleFrom runs the attack against any mac and vrfy oracles
+// (e.g. an in-process server or a recorded transcript)
+func NewMacOracleFrom(mac oracles.MACOracle, vrfy oracles.VerifyOracle) *MacOracle {
+	return &MacOracle{mac: mac, vrfy: vrfy}
 }
 
-// Mac queries the server for the tag of a given message
-func (o *MacOracle) Mac(mess []byte) []byte {
-	res, err := o.Server.Mac(mess)
-	if err != nil {
-		panic(err)
+func (o *MacOracle) Disconnect() error {
+	if o.serv == nil {
+		return nil
 	}
-	return res
+	return o.serv.Disconnect()
 }
 
-// Vrfy queries the server to check if a given (mess, tag)-pair is valid
-func (o *MacOracle) Vrfy(mess, tag []byte) bool {
-	res, err := o.Server.Vrfy(mess, tag)
-	if err != nil {
-		panic(err)
-	}
-	return res == 1
+// Mac queries the server for the tag of a given message,
+// giving up when ctx is done
+func (o *MacOracle) Mac(ctx context.Context, mess []byte) ([]byte, error) {
+	return o.mac.Tag(ctx, mess)
 }
 
-func main() {
-	o := NewMacOracle(HOST, MAC_ORACLE_PORT, VRFY_ORACLE_PORT)
-	defer o.Disconnect()
+// Vrfy queries the server to check if a given (mess, tag)-pair is valid,
+// giving up when ctx is done
+func (o *MacOracle) Vrfy(ctx context.Context, mess, tag []byte) (bool, error) {
+	return o.vrfy.Verify(ctx, mess, tag)
+}
 
-	// divide challenge text in (4) blocks
-	challenge := []byte(CHALLENGETAG)
-	if len(challenge)%16 != 0 {
-		panic(fmt.Sprintf("Challenge not padded. Len=%d", len(challenge)))
+// Forge computes the tag of a message of 2k blocks querying the oracle
+// only on 2-block messages. Since basic CBC-MAC chains the blocks,
+// with t = Mac(B_1 || B_2) we have Mac(B_1 || B_2 || B_3 || B_4) =
+// Mac((B_3 ^ t) || B_4), and so on for the following pairs of blocks.
+// The sequence of queries stops as soon as ctx is done.
+func (o *MacOracle) Forge(ctx context.Context, mess []byte) ([]byte, error) {
+	if len(mess) == 0 || len(mess)%MAC_MSG_LEN != 0 {
+		return nil, fmt.Errorf("invalid message length %d "+
+			"(must be a multiple of 2 blocks = 32 bytes)",
+			len(mess))
 	}
-	nBlocks := len(challenge) / 16 // 4
-	blocks := make([][]byte, nBlocks)
-	for i := 0; i < nBlocks; i++ {
-		blocks[i] = challenge[i*16 : i*16+16]
+	// longer messages could not be verified by the vrfy oracle
+	if len(mess) > oracle.MAX_MSG_LEN {
+		return nil, fmt.Errorf("invalid message length %d "+
+			"(at most %d bytes fit in a packet)",
+			len(mess), oracle.MAX_MSG_LEN)
 	}
 
 	// mac the first two blocks
-	var buf [32]byte
-	for i := 0; i < 32; i++ {
-		buf[i] = blocks[i/16][i%16]
-	}
-	tag := o.Mac(buf[:])
-
-	// xor the tag with the third block
-	for i := range blocks[2] {
-		blocks[2][i] ^= tag[i]
+	buf := make([]byte, MAC_MSG_LEN)
+	copy(buf, mess[:MAC_MSG_LEN])
+	tag, err := o.Mac(ctx, buf)
+	if err != nil {
+		return nil, err
 	}
 
-	// send the other two blocks after xoring the first with the tag
-	for i := 0; i < 16; i++ {
-		buf[i] = blocks[2][i] ^ tag[i]
-		buf[16+i] = blocks[3][i]
+	// mac the next two blocks after xoring the first with the tag
+	for i := MAC_MSG_LEN; i < len(mess); i += MAC_MSG_LEN {
+		copy(buf, mess[i:i+MAC_MSG_LEN])
+		for j := 0; j < BLOCK_LEN; j++ {
+			buf[j] ^= tag[j]
+		}
+		tag, err = o.Mac(ctx, buf)
+		if err != nil {
+			return nil, err
+		}
 	}
-	tag = o.Mac(buf[:])
-
-	// check the tag against full challenge
-	fmt.Println(o.Vrfy(challenge, tag))
+	return tag, nil
 }
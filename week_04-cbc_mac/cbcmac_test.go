@@ -0,0 +1,147 @@
+package cbcmac
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/gpdionisio/umcp_cryptography/oracles"
+	"github.com/gpdionisio/umcp_cryptography/week_04-cbc_mac/server"
+)
+
+const challenge = "I, the server, hereby agree that I will pay $100 to this student"
+
+// startServer serves the mac and vrfy oracles on free ports of localhost
+// until the end of the test
+func startServer(t *testing.T) (host, macPort, vrfyPort string) {
+	t.Helper()
+	s, err := server.New([]byte("YELLOW SUBMARINE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	ports := make([]string, 2)
+	for i, serve := range []func(net.Listener) error{s.ServeMac, s.ServeVrfy} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go serve(ln)
+		host, ports[i], err = net.SplitHostPort(ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return host, ports[0], ports[1]
+}
+
+// exchange sends a raw packet to the oracle at addr and reads n bytes back
+func exchange(t *testing.T, addr string, packet []byte, n int) []byte {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(packet); err != nil {
+		t.Fatal(err)
+	}
+	resp := make([]byte, n)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestForgeOverTCP(t *testing.T) {
+	host, macPort, vrfyPort := startServer(t)
+	o, err := NewMacOracle(host, macPort, vrfyPort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Disconnect()
+
+	ctx := context.Background()
+	tag, err := o.Forge(ctx, []byte(challenge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := o.Vrfy(ctx, []byte(challenge), tag); err != nil || !ok {
+		t.Fatalf("Vrfy(forged tag) = %v, %v", ok, err)
+	}
+	tag[0] ^= 1
+	if ok, err := o.Vrfy(ctx, []byte(challenge), tag); err != nil || ok {
+		t.Errorf("Vrfy(altered tag) = %v, %v", ok, err)
+	}
+
+	// the client refuses these lengths, so the packets are written by hand
+	for _, n := range []int{1, 17, 33} {
+		packet := append([]byte{byte(n)}, make([]byte, n+16+1)...)
+		resp := exchange(t, net.JoinHostPort(host, vrfyPort), packet, 2)
+		if !bytes.Equal(resp, []byte("-1")) {
+			t.Errorf("vrfy(%d bytes) replied %q, want \"-1\"", n, resp)
+		}
+	}
+	for _, n := range []int{16, 31, 48} {
+		packet := append([]byte{byte(n)}, make([]byte, n+1)...)
+		resp := exchange(t, net.JoinHostPort(host, macPort), packet, 16)
+		if !bytes.Equal(resp, make([]byte, 16)) {
+			t.Errorf("mac(%d bytes) replied %x, want the all-zero tag", n, resp)
+		}
+	}
+}
+
+func TestForgeReplay(t *testing.T) {
+	s, err := server.New([]byte("YELLOW SUBMARINE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	rec := &oracles.Recorder{MAC: s, Verifier: s}
+	for _, n := range []int{16, 31, 48} {
+		if _, err := rec.Tag(ctx, make([]byte, n)); !errors.Is(err, oracles.ErrMalformed) {
+			t.Fatalf("Tag(%d bytes): got %v, want %v", n, err, oracles.ErrMalformed)
+		}
+	}
+	o := NewMacOracleFrom(rec, rec)
+	tag, err := o.Forge(ctx, []byte(challenge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := o.Vrfy(ctx, []byte(challenge), tag); err != nil || !ok {
+		t.Fatalf("Vrfy(forged tag) = %v, %v", ok, err)
+	}
+
+	r := oracles.NewReplayer(rec.Exchanges())
+	if _, err := r.Tag(ctx, make([]byte, 48)); !errors.Is(err, oracles.ErrMalformed) {
+		t.Errorf("replayed Tag(48 bytes): got %v, want %v", err, oracles.ErrMalformed)
+	}
+	o = NewMacOracleFrom(r, r)
+	replayed, err := o.Forge(ctx, []byte(challenge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(replayed, tag) {
+		t.Errorf("replayed Forge = %x, recorded %x", replayed, tag)
+	}
+	if ok, err := o.Vrfy(ctx, []byte(challenge), replayed); err != nil || !ok {
+		t.Errorf("replayed Vrfy(forged tag) = %v, %v", ok, err)
+	}
+}
+
+func TestForgeTooLong(t *testing.T) {
+	s, err := server.New([]byte("YELLOW SUBMARINE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := NewMacOracleFrom(s, s)
+	if _, err := o.Forge(context.Background(), make([]byte, 256)); err == nil {
+		t.Error("Forge(256 bytes): no error")
+	}
+	if _, err := o.Forge(context.Background(), make([]byte, 224)); err != nil {
+		t.Errorf("Forge(224 bytes): %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/gpdionisio/umcp_cryptography/week_04-cbc_mac/server"
+)
+
+func main() {
+	macAddr := flag.String("mac-addr", ":49102", "address of the mac oracle")
+	vrfyAddr := flag.String("vrfy-addr", ":49103", "address of the vrfy oracle")
+	keyHex := flag.String("key", "", "AES key in hex (random if empty)")
+	flag.Parse()
+
+	var key []byte
+	var err error
+	if *keyHex != "" {
+		key, err = hex.DecodeString(*keyHex)
+	} else {
+		key = make([]byte, 16)
+		_, err = rand.Read(key)
+		fmt.Printf("Generated key %X\n", key)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	s, err := server.New(key)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("CBC-MAC oracle listening on %s (mac) and %s (vrfy)\n",
+		*macAddr, *vrfyAddr)
+	if err := s.ListenAndServe(*macAddr, *vrfyAddr); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -1,3 +0,0 @@
-module w4_assign
-
-go 1.18
@@ -1,24 +1,50 @@
 package oracle
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"net"
 	"strconv"
+	"time"
+
+	"github.com/gpdionisio/umcp_cryptography/oracles"
 )
 
+// MAX_MSG_LEN is the longest message the length byte of a packet holds
+const MAX_MSG_LEN = 255 //nolint
+
 type Server struct {
-	macSock, vrfySock net.Conn
+	macSock, vrfySock *oracles.FramedConn
+
+	// Timeouts of the network operations
+	// (oracles.DEFAULT_TIMEOUT if not set)
+	DialTimeout, ReadTimeout, WriteTimeout time.Duration
 }
 
 // Connect establishes a connection to the server
 func (s *Server) Connect(host, portMac, portVrfy string) error {
-	var err error
-	s.macSock, err = net.Dial("tcp", host+":"+portMac)
+	return s.ConnectContext(context.Background(), host, portMac, portVrfy)
+}
+
+// ConnectContext establishes a connection to the server,
+// giving up when ctx is done
+func (s *Server) ConnectContext(ctx context.Context,
+	host, portMac, portVrfy string) error {
+	macConn, err := oracles.Dial(ctx, net.JoinHostPort(host, portMac),
+		s.DialTimeout)
+	if err != nil {
+		return err
+	}
+	vrfyConn, err := oracles.Dial(ctx, net.JoinHostPort(host, portVrfy),
+		s.DialTimeout)
 	if err != nil {
+		macConn.Close()
 		return err
 	}
-	s.vrfySock, err = net.Dial("tcp", host+":"+portVrfy)
-	return err
+	s.macSock = oracles.NewFramedConn(macConn)
+	s.vrfySock = oracles.NewFramedConn(vrfyConn)
+	return nil
 }
 
 // Disconnect drops the connection
@@ -34,6 +60,11 @@ func (s *Server) Disconnect() error {
 // < mlength(1) || message(mlength) || null-terminator(1) >
 // Returns tag
 func (s *Server) Mac(mess []byte) ([]byte, error) {
+	return s.MacContext(context.Background(), mess)
+}
+
+// MacContext is like Mac but gives up when ctx is done
+func (s *Server) MacContext(ctx context.Context, mess []byte) ([]byte, error) {
 	mlength := len(mess)
 	if mlength != 32 {
 		return []byte{}, fmt.Errorf("invalid message length %d "+
@@ -46,19 +77,15 @@ func (s *Server) Mac(mess []byte) ([]byte, error) {
 	buf[mlength+1] = 0x00
 
 	// send data
-	_, err := s.macSock.Write(buf)
+	err := s.macSock.Write(ctx, buf, s.WriteTimeout)
 	if err != nil {
-		return []byte{}, fmt.Errorf("error writing: %v", err)
+		return []byte{}, fmt.Errorf("error writing: %w", err)
 	}
 
 	// receive response
-	resp := make([]byte, 16)
-	l, err := s.macSock.Read(resp)
+	resp, err := s.macSock.ReadFull(ctx, 16, s.ReadTimeout)
 	if err != nil {
-		return []byte{}, fmt.Errorf("error reading: %v", err)
-	}
-	if l != 16 {
-		return []byte{}, fmt.Errorf("invalid reply len: %d", l)
+		return []byte{}, fmt.Errorf("error reading: %w", err)
 	}
 	return resp, nil
 }
@@ -67,15 +94,25 @@ func (s *Server) Mac(mess []byte) ([]byte, error) {
 // < mlength(1) || message(mlength) || tag(16) || null-terminator(1) >
 // Returns tag
 func (s *Server) Vrfy(mess, tag []byte) (int, error) {
+	return s.VrfyContext(context.Background(), mess, tag)
+}
+
+// VrfyContext is like Vrfy but gives up when ctx is done
+func (s *Server) VrfyContext(ctx context.Context, mess, tag []byte) (int, error) {
 	mlength := len(mess)
 	if mlength%16 != 0 {
 		return -1, fmt.Errorf("invalid message length %d "+
 			"(must be a multiple of the block size 16)",
 			mlength)
 	}
+	if mlength > MAX_MSG_LEN {
+		return -1, fmt.Errorf("invalid message length %d "+
+			"(at most %d bytes fit in a packet)",
+			mlength, MAX_MSG_LEN)
+	}
 	if len(tag) != 16 {
 		return -1, fmt.Errorf("invalid tag length %d "+
-			"(must be exactlt one block = 16 bytes)",
+			"(must be exactly one block = 16 bytes)",
 			len(tag))
 	}
 	buf := make([]byte, mlength+2+16)
@@ -85,20 +122,42 @@ func (s *Server) Vrfy(mess, tag []byte) (int, error) {
 	buf[mlength+1+16] = 0x00
 
 	// send data
-	_, err := s.vrfySock.Write(buf)
+	err := s.vrfySock.Write(ctx, buf, s.WriteTimeout)
 	if err != nil {
-		return -1, fmt.Errorf("error writing: %v", err)
+		return -1, fmt.Errorf("error writing: %w", err)
 	}
 
-	// receive response
-	resp := make([]byte, 2)
-	_, err = s.vrfySock.Read(resp)
+	// receive response (always 2 bytes long)
+	resp, err := s.vrfySock.ReadFull(ctx, 2, s.ReadTimeout)
 	if err != nil {
-		return -1, fmt.Errorf("error reading: %v", err)
+		return -1, fmt.Errorf("error reading: %w", err)
 	}
-	res, err := strconv.Atoi(string(resp[0]))
+	res, err := strconv.Atoi(string(bytes.TrimRight(resp, "\x00")))
 	if err != nil {
 		return -1, fmt.Errorf("error converting: %v", err)
 	}
+	if res == -1 {
+		return -1, oracles.ErrMalformed
+	}
 	return res, nil
 }
+
+// Tag implements oracles.MACOracle
+func (s *Server) Tag(ctx context.Context, mess []byte) ([]byte, error) {
+	return s.MacContext(ctx, mess)
+}
+
+// Verify implements oracles.VerifyOracle
+func (s *Server) Verify(ctx context.Context, mess, tag []byte) (bool, error) {
+	res, err := s.VrfyContext(ctx, mess, tag)
+	if err != nil {
+		return false, err
+	}
+	switch res {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid reply %d", res)
+}
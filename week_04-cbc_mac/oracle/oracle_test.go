@@ -0,0 +1,110 @@
+package oracle
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gpdionisio/umcp_cryptography/week_04-cbc_mac/server"
+)
+
+// slowConn writes one byte at a time
+type slowConn struct {
+	net.Conn
+}
+
+func (c slowConn) Write(b []byte) (int, error) {
+	for i := range b {
+		if _, err := c.Conn.Write(b[i : i+1]); err != nil {
+			return i, err
+		}
+	}
+	return len(b), nil
+}
+
+// slowListener accepts slowConns
+type slowListener struct {
+	net.Listener
+}
+
+func (l slowListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return slowConn{conn}, nil
+}
+
+func listen(t *testing.T) (net.Listener, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return slowListener{ln}, port
+}
+
+func TestRepliesInSingleBytes(t *testing.T) {
+	s, err := server.New([]byte("YELLOW SUBMARINE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	macLn, macPort := listen(t)
+	vrfyLn, vrfyPort := listen(t)
+	go s.ServeMac(macLn)
+	go s.ServeVrfy(vrfyLn)
+	t.Cleanup(func() { s.Close() })
+
+	c := Server{ReadTimeout: 5 * time.Second}
+	if err := c.Connect("127.0.0.1", macPort, vrfyPort); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	ctx := context.Background()
+	mess := []byte("sixteen bytes ..sixteen bytes ..")
+	tag, err := c.MacContext(ctx, mess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := s.Tag(ctx, mess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tag, want) {
+		t.Fatalf("Mac = %x, want %x", tag, want)
+	}
+
+	bad := append([]byte{}, tag...)
+	bad[0] ^= 1
+	for _, q := range []struct {
+		tag  []byte
+		want int
+	}{
+		{tag, 1},
+		{bad, 0},
+		{tag, 1},
+	} {
+		res, err := c.VrfyContext(ctx, mess, q.tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != q.want {
+			t.Errorf("Vrfy(%x) = %d, want %d", q.tag, res, q.want)
+		}
+	}
+}
+
+func TestVrfyTooLong(t *testing.T) {
+	// rejected before anything is sent: no connection is needed
+	var s Server
+	if _, err := s.VrfyContext(context.Background(), make([]byte, 256), make([]byte, 16)); err == nil {
+		t.Error("Vrfy(256 bytes): no error")
+	}
+}
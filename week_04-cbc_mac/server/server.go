@@ -0,0 +1,213 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/gpdionisio/umcp_cryptography/oracles"
+)
+
+const (
+	BLOCK_LEN   = 16 //nolint
+	MAC_MSG_LEN = 32 //nolint
+)
+
+// Server is a local replacement of proj4's mac.c and vrfy.c: it tags
+// 2-block messages and verifies tags with basic CBC-MAC over AES
+type Server struct {
+	block cipher.Block
+
+	mu    sync.Mutex
+	lns   []net.Listener
+	conns map[net.Conn]struct{}
+}
+
+// New creates a CBC-MAC server for the given AES key (16, 24 or 32 bytes)
+func New(key []byte) (*Server, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{block: block, conns: make(map[net.Conn]struct{})}, nil
+}
+
+// Tag computes in-process the tag of a 2-block message, the only length
+// mac.c accepts. It implements oracles.MACOracle.
+func (s *Server) Tag(ctx context.Context, mess []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(mess) != MAC_MSG_LEN {
+		return nil, fmt.Errorf("%w: invalid message length %d "+
+			"(this oracle macs 2 blocks = 32 bytes)",
+			oracles.ErrMalformed, len(mess))
+	}
+	return s.cbcmac(mess), nil
+}
+
+// Verify checks in-process the tag of a message whose length is a multiple
+// of the block length. It implements oracles.VerifyOracle.
+func (s *Server) Verify(ctx context.Context, mess, tag []byte) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if len(mess) == 0 || len(mess)%BLOCK_LEN != 0 {
+		return false, fmt.Errorf("%w: invalid message length %d "+
+			"(must be a multiple of the block size 16)",
+			oracles.ErrMalformed, len(mess))
+	}
+	return subtle.ConstantTimeCompare(s.cbcmac(mess), tag) == 1, nil
+}
+
+// cbcmac computes the basic CBC-MAC (null IV, no length prefix) of the
+// message. As cbcmac.c does, the message is padded with 0-bytes to a
+// multiple of the block length.
+func (s *Server) cbcmac(mess []byte) []byte {
+	tag := make([]byte, BLOCK_LEN)
+	blk := make([]byte, BLOCK_LEN)
+	for i := 0; i < len(mess); i += BLOCK_LEN {
+		for j := range blk {
+			blk[j] = 0x00
+		}
+		copy(blk, mess[i:])
+		for j := range blk {
+			blk[j] ^= tag[j]
+		}
+		s.block.Encrypt(tag, blk)
+	}
+	return tag
+}
+
+// ListenAndServe listens on the TCP addresses macAddr and vrfyAddr and
+// serves both oracles until one of the listeners fails
+func (s *Server) ListenAndServe(macAddr, vrfyAddr string) error {
+	macLn, err := net.Listen("tcp", macAddr)
+	if err != nil {
+		return err
+	}
+	vrfyLn, err := net.Listen("tcp", vrfyAddr)
+	if err != nil {
+		macLn.Close()
+		return err
+	}
+	errs := make(chan error, 2)
+	go func() { errs <- s.ServeMac(macLn) }()
+	go func() { errs <- s.ServeVrfy(vrfyLn) }()
+	err = <-errs
+	s.Close()
+	<-errs
+	return err
+}
+
+// ServeMac accepts connections on the listener and answers tag requests
+// < mlength(1) || message(mlength) || null-terminator(1) >
+// with the 16 bytes tag. As in mac.c, only 2-block messages are tagged:
+// the all-zero tag is returned for any other length.
+func (s *Server) ServeMac(ln net.Listener) error {
+	return s.serve(ln, func(r *bufio.Reader) ([]byte, error) {
+		mlength, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, int(mlength)+1)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if mlength != MAC_MSG_LEN {
+			return make([]byte, BLOCK_LEN), nil
+		}
+		return s.cbcmac(buf[:mlength]), nil
+	})
+}
+
+// ServeVrfy accepts connections on the listener and answers verification
+// requests < mlength(1) || message(mlength) || tag(16) || null-terminator(1) >
+// with "1" if the tag is valid, "0" if not and "-1" if the message length
+// is not a (non-zero) multiple of the block length
+func (s *Server) ServeVrfy(ln net.Listener) error {
+	return s.serve(ln, func(r *bufio.Reader) ([]byte, error) {
+		mlength, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, int(mlength)+BLOCK_LEN+1)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		match := -1
+		if mlength > 0 && mlength%BLOCK_LEN == 0 {
+			match = subtle.ConstantTimeCompare(s.cbcmac(buf[:mlength]),
+				buf[mlength:int(mlength)+BLOCK_LEN])
+		}
+		resp := make([]byte, 2)
+		copy(resp, strconv.Itoa(match))
+		return resp, nil
+	})
+}
+
+// Close stops the listeners and drops all the clients
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	for _, ln := range s.lns {
+		if e := ln.Close(); e != nil && !errors.Is(e, net.ErrClosed) {
+			err = e
+		}
+	}
+	s.lns = nil
+	for conn := range s.conns {
+		conn.Close()
+		delete(s.conns, conn)
+	}
+	return err
+}
+
+// serve accepts clients and, for each one, replies to every request
+// parsed by handle until the client disconnects
+func (s *Server) serve(ln net.Listener,
+	handle func(r *bufio.Reader) ([]byte, error)) error {
+	s.mu.Lock()
+	s.lns = append(s.lns, ln)
+	s.mu.Unlock()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		go func() {
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+			r := bufio.NewReader(conn)
+			for {
+				resp, err := handle(r)
+				if err != nil {
+					return
+				}
+				if _, err := conn.Write(resp); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/gpdionisio/umcp_cryptography/week_07-rsa/server"
+)
+
+// loadKey reads a PEM encoded (PKCS #1 or PKCS #8) RSA private key
+func loadKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	blk, _ := pem.Decode(data)
+	if blk == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(blk.Bytes); err == nil {
+		return key, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(blk.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA private key")
+	}
+	return key, nil
+}
+
+// saveKey writes the private key PEM encoded (PKCS #1)
+func saveKey(path string, key *rsa.PrivateKey) error {
+	data := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	return os.WriteFile(path, data, 0600)
+}
+
+func main() {
+	signAddr := flag.String("sign-addr", ":49104", "address of the sign oracle")
+	vrfyAddr := flag.String("vrfy-addr", ":49105", "address of the vrfy oracle")
+	keyPath := flag.String("key", "", "PEM file of the RSA private key "+
+		"(a new 1024-bit key is generated if empty)")
+	savePath := flag.String("save-key", "", "write the generated key to this file")
+	flag.Parse()
+
+	var key *rsa.PrivateKey
+	var err error
+	if *keyPath != "" {
+		key, err = loadKey(*keyPath)
+	} else {
+		key, err = rsa.GenerateKey(rand.Reader, 1024)
+		if err == nil && *savePath != "" {
+			err = saveKey(*savePath, key)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if key.N.BitLen() < 1024 {
+		fmt.Fprintf(os.Stderr, "modulus too short (%d bits)\n", key.N.BitLen())
+		os.Exit(1)
+	}
+
+	s := server.New(key)
+	fmt.Printf("N = %s\n", key.N.Text(16))
+	fmt.Printf("e = %x\n", key.E)
+
+	fmt.Printf("RSA oracle listening on %s (sign) and %s (vrfy)\n",
+		*signAddr, *vrfyAddr)
+	if err := s.ListenAndServe(*signAddr, *vrfyAddr); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -1,3 +0,0 @@
-module w7_assign
-
-go 1.18
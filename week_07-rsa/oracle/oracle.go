@@ -1,32 +1,84 @@
 package oracle
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"net"
-	"strings"
+	"time"
+
+	"github.com/gpdionisio/umcp_cryptography/oracles"
 )
 
 const (
-	MAX_PACKET_LEN = 8192 //nolint
-	NOT_BINARY_STR_ERR = -1 //nolint
-	MISSING_DELIMITER_ERR = -2 //nolint
-	ORIGINAL_MSG_ERR = -3 //nolint
+	MAX_PACKET_LEN        = 8192 //nolint
+	NOT_BINARY_STR_ERR    = -1   //nolint
+	MISSING_DELIMITER_ERR = -2   //nolint
+	ORIGINAL_MSG_ERR      = -3   //nolint
+)
+
+// Errors replied by the server
+var (
+	ErrNotBinary        = errors.New("message is not a valid binary string")
+	ErrMissingDelimiter = errors.New("missing delimiter between message and signature")
+	ErrOriginalMessage  = errors.New("cannot request a signature on the original message")
 )
 
+func init() {
+	oracles.RegisterError("rsa_not_binary", ErrNotBinary)
+	oracles.RegisterError("rsa_missing_delimiter", ErrMissingDelimiter)
+	oracles.RegisterError("rsa_original_message", ErrOriginalMessage)
+}
+
+// codeToErr converts the error codes replied by the server
+func codeToErr(code int) error {
+	switch code {
+	case NOT_BINARY_STR_ERR:
+		return ErrNotBinary
+	case MISSING_DELIMITER_ERR:
+		return ErrMissingDelimiter
+	case ORIGINAL_MSG_ERR:
+		return ErrOriginalMessage
+	}
+	return fmt.Errorf("invalid reply %d", code)
+}
+
 type Server struct {
-	signSock, vrfySock net.Conn
+	signSock, vrfySock *oracles.FramedConn
+
+	// Timeouts of the network operations
+	// (oracles.DEFAULT_TIMEOUT if not set)
+	DialTimeout, ReadTimeout, WriteTimeout time.Duration
+
+	// IdleTimeout ends the replies of the sign oracle, which are not
+	// terminated (oracles.DEFAULT_IDLE if not set)
+	IdleTimeout time.Duration
 }
 
 // Connect establishes a connection to the server
 func (s *Server) Connect(host, portSign, portVrfy string) error {
-	var err error
-	s.signSock, err = net.Dial("tcp", host+":"+portSign)
+	return s.ConnectContext(context.Background(), host, portSign, portVrfy)
+}
+
+// ConnectContext establishes a connection to the server,
+// giving up when ctx is done
+func (s *Server) ConnectContext(ctx context.Context,
+	host, portSign, portVrfy string) error {
+	signConn, err := oracles.Dial(ctx, net.JoinHostPort(host, portSign),
+		s.DialTimeout)
+	if err != nil {
+		return err
+	}
+	vrfyConn, err := oracles.Dial(ctx, net.JoinHostPort(host, portVrfy),
+		s.DialTimeout)
 	if err != nil {
+		signConn.Close()
 		return err
 	}
-	s.vrfySock, err = net.Dial("tcp", host+":"+portVrfy)
-	return err
+	s.signSock = oracles.NewFramedConn(signConn)
+	s.vrfySock = oracles.NewFramedConn(vrfyConn)
+	return nil
 }
 
 // Disconnect drops the connection
@@ -44,29 +96,58 @@ func convToBinStr(m *big.Int) []byte {
 	return []byte(s)
 }
 
-func readSock(sock net.Conn) *big.Int {
-	resp := make([]byte, MAX_PACKET_LEN)
-	_, err := sock.Read(resp)
+func (s *Server) writeSock(ctx context.Context, sock *oracles.FramedConn,
+	buf []byte) error {
+	err := sock.Write(ctx, buf, s.WriteTimeout)
 	if err != nil {
-		panic(fmt.Errorf("error reading: %v", err))
+		return fmt.Errorf("error writing: %w", err)
 	}
-	res := strings.ReplaceAll(string(resp), string(0x00), "")
+	return nil
+}
+
+// isCode tells whether the reply is a whole error code (-1, -2 or -3)
+func isCode(resp []byte) bool {
+	return len(resp) == 2 && resp[0] == '-'
+}
+
+// isBit tells whether the reply is a whole answer of the vrfy oracle
+// (0, 1 or an error code)
+func isBit(resp []byte) bool {
+	return (len(resp) == 1 && resp[0] != '-') || isCode(resp)
+}
+
+// readSock reads a reply < binary string or error code >. As in
+// rsa_server.py, replies are not terminated: a reply ends when complete
+// says so, or else when the connection stays idle for IdleTimeout.
+func (s *Server) readSock(ctx context.Context, sock *oracles.FramedConn,
+	complete func([]byte) bool) (*big.Int, error) {
+	resp, err := sock.ReadUnterminated(ctx, MAX_PACKET_LEN, s.IdleTimeout,
+		s.ReadTimeout, complete)
+	if err != nil {
+		return nil, fmt.Errorf("error reading: %w", err)
+	}
+	res := string(resp)
 	i := new(big.Int)
 	_, ok := i.SetString(res, 2)
 	if !ok {
 		// try base two
 		_, ok := i.SetString(res, 10)
 		if !ok {
-			panic(fmt.Errorf("error converting to int: %v", err))
+			return nil, fmt.Errorf("error converting to int: %q", res)
 		}
 	}
-	return i
+	return i, nil
 }
 
 // Sends message (to sign) with following packet structure
 // < message || null-terminator("X") >
-// Returns signature
-func (s *Server) Sign(mess *big.Int) *big.Int {
+// Returns signature, or ErrNotBinary / ErrOriginalMessage
+func (s *Server) Sign(mess *big.Int) (*big.Int, error) {
+	return s.SignContext(context.Background(), mess)
+}
+
+// SignContext is like Sign but gives up when ctx is done
+func (s *Server) SignContext(ctx context.Context, mess *big.Int) (*big.Int, error) {
 	messBuf := convToBinStr(mess)
 	mlength := len(messBuf)
 	buf := make([]byte, len(messBuf)+1)
@@ -74,50 +155,75 @@ func (s *Server) Sign(mess *big.Int) *big.Int {
 	buf[mlength] = byte('X')
 
 	// send data
-	_, err := s.signSock.Write(buf)
-	if err != nil {
-		panic(fmt.Errorf("error writing: %v", err))
+	if err := s.writeSock(ctx, s.signSock, buf); err != nil {
+		return nil, err
 	}
 
 	// receive response
-	i := readSock(s.signSock)
-	if int(i.Int64()) == NOT_BINARY_STR_ERR {
-		fmt.Println("[ERR] Message is not a valid binary string")
+	i, err := s.readSock(ctx, s.signSock, isCode)
+	if err != nil {
+		return nil, err
 	}
-	if int(i.Int64()) == ORIGINAL_MSG_ERR {
-		fmt.Println("[ERR] You cannot request a signature on the original message!")
+	if i.Sign() < 0 {
+		return nil, codeToErr(int(i.Int64()))
 	}
-	return i
+	return i, nil
 }
 
 // Sends message (to verify) with following packet structure
 // < message | ":" | signature >
-// Returns int check
-func (s *Server) Vrfy(mess, sig *big.Int) int {
+// Returns int check (1 if valid, 0 if not), or
+// ErrNotBinary / ErrMissingDelimiter
+func (s *Server) Vrfy(mess, sig *big.Int) (int, error) {
+	return s.VrfyContext(context.Background(), mess, sig)
+}
+
+// VrfyContext is like Vrfy but gives up when ctx is done
+func (s *Server) VrfyContext(ctx context.Context, mess, sig *big.Int) (int, error) {
 	messBuf := convToBinStr(mess)
 	sigBuf := convToBinStr(sig)
 	mlength := len(messBuf)
 	slength := len(sigBuf)
 	buf := make([]byte, mlength+slength+2)
-	buf[0] = byte(mlength)
 	copy(buf[0:mlength], messBuf)
 	buf[mlength] = byte(':')
 	copy(buf[mlength+1:mlength+1+slength], sigBuf)
 	buf[mlength+slength+1] = byte('X')
 
 	// send data
-	_, err := s.vrfySock.Write(buf)
-	if err != nil {
-		panic(fmt.Errorf("error writing: %v", err))
+	if err := s.writeSock(ctx, s.vrfySock, buf); err != nil {
+		return 0, err
 	}
 
 	// receive response
-	i := int(readSock(s.vrfySock).Int64())
-	if i == NOT_BINARY_STR_ERR {
-		fmt.Println("[ERR] Message is not a valid binary string")
+	res, err := s.readSock(ctx, s.vrfySock, isBit)
+	if err != nil {
+		return 0, err
+	}
+	i := int(res.Int64())
+	if i < 0 {
+		return 0, codeToErr(i)
 	}
-	if i == MISSING_DELIMITER_ERR {
-		fmt.Println("[ERR] Missing delimiter between message and signature")
+	return i, nil
+}
+
+// Signature implements oracles.SigningOracle: the message and the signature
+// are big-endian integers
+func (s *Server) Signature(ctx context.Context, mess []byte) ([]byte, error) {
+	sig, err := s.SignContext(ctx, new(big.Int).SetBytes(mess))
+	if err != nil {
+		return nil, err
+	}
+	return sig.Bytes(), nil
+}
+
+// Verify implements oracles.VerifyOracle: the message and the signature
+// are big-endian integers
+func (s *Server) Verify(ctx context.Context, mess, sig []byte) (bool, error) {
+	res, err := s.VrfyContext(ctx, new(big.Int).SetBytes(mess),
+		new(big.Int).SetBytes(sig))
+	if err != nil {
+		return false, err
 	}
-	return i
+	return res == 1, nil
 }
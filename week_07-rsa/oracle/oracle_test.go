@@ -0,0 +1,114 @@
+package oracle_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gpdionisio/umcp_cryptography/week_07-rsa/oracle"
+	"github.com/gpdionisio/umcp_cryptography/week_07-rsa/server"
+)
+
+// slowConn writes one byte at a time
+type slowConn struct {
+	net.Conn
+}
+
+func (c slowConn) Write(b []byte) (int, error) {
+	for i := range b {
+		if _, err := c.Conn.Write(b[i : i+1]); err != nil {
+			return i, err
+		}
+	}
+	return len(b), nil
+}
+
+// slowListener accepts slowConns
+type slowListener struct {
+	net.Listener
+}
+
+func (l slowListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return slowConn{conn}, nil
+}
+
+func listen(t *testing.T) (net.Listener, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return slowListener{ln}, port
+}
+
+func TestRepliesInSingleBytes(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := server.New(key)
+	signLn, signPort := listen(t)
+	vrfyLn, vrfyPort := listen(t)
+	go s.ServeSign(signLn)
+	go s.ServeVrfy(vrfyLn)
+	t.Cleanup(func() { s.Close() })
+
+	c := oracle.Server{ReadTimeout: 5 * time.Second}
+	if err := c.Connect("127.0.0.1", signPort, vrfyPort); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	ctx := context.Background()
+	m := big.NewInt(5)
+	sig, err := c.SignContext(ctx, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := s.Signature(ctx, m.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig.Cmp(new(big.Int).SetBytes(want)) != 0 {
+		t.Fatalf("Sign(5) = %x, want %x", sig, want)
+	}
+
+	checks := []struct {
+		m, sig *big.Int
+		want   int
+	}{
+		{m, sig, 1},
+		{big.NewInt(6), sig, 0},
+	}
+	for _, ch := range checks {
+		res, err := c.VrfyContext(ctx, ch.m, ch.sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != ch.want {
+			t.Errorf("Vrfy(%v, sig) = %d, want %d", ch.m, res, ch.want)
+		}
+	}
+
+	chall := new(big.Int).SetBytes([]byte(server.CHALLENGE))
+	if _, err := c.SignContext(ctx, chall); !errors.Is(err, oracle.ErrOriginalMessage) {
+		t.Errorf("Sign(challenge): got %v, want %v", err, oracle.ErrOriginalMessage)
+	}
+	// the connection is still in step after an error code
+	if sig2, err := c.SignContext(ctx, m); err != nil || sig2.Cmp(sig) != 0 {
+		t.Errorf("Sign(5) after an error = %x, %v", sig2, err)
+	}
+}
@@ -1,9 +1,15 @@
-package main
+// Package plainrsa forges signatures for J. Random Hacker's RSA-based
+// signature scheme given a signing oracle.
+package plainrsa
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/big"
-	"w7_assign/oracle"
+
+	"github.com/gpdionisio/umcp_cryptography/oracles"
+	"github.com/gpdionisio/umcp_cryptography/week_07-rsa/oracle"
 )
 
 // The scheme works as follows: the public key is a standard RSA public key
@@ -12,91 +18,127 @@ import (
 // set [M = 0x00 m 0x00 m] and then compute the signature M^d mod N.
 // If m is shorter than 63 bytes, 0-bytes are first preprended to make its
 // length exactly 63 bytes.
-
-const (
-	HOST = "128.8.130.16" //nolint
-	SIGN_ORACLE_PORT = "49104" //nolint
-	VRFY_ORACLE_PORT = "49105" //nolint
-
-	// Public key
-	N_HEX = "a99263f5cd9a6c3d93411fbf682859a07b5e41c38abade2a551798e6c8af5af0"+ //nolint
-            "8dee5c7420c99f0f3372e8f2bfc4d0c85115b45a0abc540349bf08b251a80b85"+
-            "975214248dffe57095248d1c7e375125c1da25227926c99a5ba4432dfcfdae3"+
-            "00b795f1764af043e7c1a8e070f5229a4cbc6c5680ff2cd6fa1d62d39faf3d41d"
-	e_HEX = "10001" //nolint
-
-	// Challenge text
-	M = "Crypto is hard --- even schemes that look complex can be broken" //nolint
-
-
-)
-
 type RsaOracle struct {
-	oracle.Server
+	signer   oracles.SigningOracle
+	verifier oracles.VerifyOracle
+	serv     *oracle.Server // set only when connected to a remote server
 }
 
-func NewMacOracle(host, macPort, vrfyPort string) *RsaOracle {
-	var o RsaOracle
-	err := o.Connect(host, macPort, vrfyPort)
+func NewRsaOracle(host, signPort, vrfyPort string) (*RsaOracle, error) {
+	var serv oracle.Server
+	err := serv.Connect(host, signPort, vrfyPort)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	fmt.Printf("Connected to server at %s:[%s/%s]\n",
-		host, macPort, vrfyPort)
-	return &o
+	return &RsaOracle{signer: &serv, verifier: &serv, serv: &serv}, nil
 }
 
-func (o *RsaOracle) Disconnect() {
-	err := o.Server.Disconnect()
-	if err != nil {
-		panic(err)
+// NewRsaOracleFrom runs the attack against any sign and vrfy oracles
+// (e.g. an in-process server or a recorded transcript)
+func NewRsaOracleFrom(signer oracles.SigningOracle,
+	verifier oracles.VerifyOracle) *RsaOracle {
+	return &RsaOracle{signer: signer, verifier: verifier}
+}
+
+func (o *RsaOracle) Disconnect() error {
+	if o.serv == nil {
+		return nil
 	}
-	fmt.Println("Disconnected from rsa oracle")
+	return o.serv.Disconnect()
 }
 
-func main() {
-	o := NewMacOracle(HOST, SIGN_ORACLE_PORT, VRFY_ORACLE_PORT)
-	defer o.Disconnect()
+// Sign queries the server for the signature of m
+func (o *RsaOracle) Sign(m *big.Int) (*big.Int, error) {
+	return o.SignContext(context.Background(), m)
+}
 
-	N := new(big.Int)
-	N.SetString(N_HEX, 16)
-	e := new(big.Int)
-	e.SetString(e_HEX, 16)
-	chall := new(big.Int).SetBytes([]byte(M))
+// SignContext is like Sign but gives up when ctx is done
+func (o *RsaOracle) SignContext(ctx context.Context, m *big.Int) (*big.Int, error) {
+	sig, err := o.signer.Signature(ctx, m.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(sig), nil
+}
 
-	fmt.Printf("N = %s\n", N.Text(10))
-	fmt.Printf("e = %s\n", e.Text(10))
-	fmt.Printf("chall = %s\n", chall.Text(10))
+// Vrfy queries the server to check if sig is a valid signature of m
+func (o *RsaOracle) Vrfy(m, sig *big.Int) (bool, error) {
+	return o.VrfyContext(context.Background(), m, sig)
+}
 
-	// try oracle
+// VrfyContext is like Vrfy but gives up when ctx is done
+func (o *RsaOracle) VrfyContext(ctx context.Context, m, sig *big.Int) (bool, error) {
+	return o.verifier.Verify(ctx, m.Bytes(), sig.Bytes())
+}
+
+// Check makes sure the oracle signs and verifies correctly,
+// and refuses to sign the challenge (unless chall is nil)
+func (o *RsaOracle) Check(ctx context.Context, chall *big.Int) error {
 	m := big.NewInt(5)
-	sig := o.Sign(m)
-	if o.Vrfy(m, sig) == 1 &&
-	   o.Vrfy(big.NewInt(6), sig) == 0 &&
-	   o.Vrfy(m, big.NewInt(5)) == 0 &&
-	   int(o.Sign(chall).Int64()) == -3 {
-		fmt.Println("Oracle working and ready to go!")
+	sig, err := o.SignContext(ctx, m)
+	if err != nil {
+		return err
+	}
+	checks := []struct {
+		m, sig *big.Int
+		valid  bool
+	}{
+		{m, sig, true},
+		{big.NewInt(6), sig, false},
+		{m, big.NewInt(5), false},
 	}
+	for _, c := range checks {
+		ok, err := o.VrfyContext(ctx, c.m, c.sig)
+		if err != nil {
+			return err
+		}
+		if ok != c.valid {
+			return fmt.Errorf("vrfy(%v, %v) = %v", c.m, c.sig, ok)
+		}
+	}
+	if chall == nil {
+		return nil
+	}
+	_, err = o.SignContext(ctx, chall)
+	if !errors.Is(err, oracle.ErrOriginalMessage) {
+		return fmt.Errorf("signing the challenge: expected %v, got %v",
+			oracle.ErrOriginalMessage, err)
+	}
+	return nil
+}
 
+// Forge computes the signature of chall (an even message) with the
+// public key (N, e), without ever asking the oracle to sign it.
+// The sequence of queries stops as soon as ctx is done.
+func (o *RsaOracle) Forge(ctx context.Context, N, e, chall *big.Int) (*big.Int, error) {
+	if chall.Bit(0) != 0 {
+		return nil, errors.New("the message to forge must be even")
+	}
 	One := big.NewInt(1)
 	Two := big.NewInt(2)
 
 	// Idea:
 	// Sig(1) = (2^512 + 1)^d (mod N)
-	sig1 := o.Sign(One)
+	sig1, err := o.SignContext(ctx, One)
+	if err != nil {
+		return nil, err
+	}
 	x := new(big.Int).Exp(sig1, e, N)
 	pow2 := new(big.Int).Exp(Two, big.NewInt(512), N)
 	pow2.Mod(pow2.Add(pow2, One), N)
 	if x.Cmp(pow2) != 0 {
-		panic("sig1 != (2^512 + 1)^d (mod N)")
+		return nil, errors.New("sig1 != (2^512 + 1)^d (mod N)")
 	}
 	// Sig(2) = (2^512 + 1)^d 2^d (mod N)
-	sig2 := o.Sign(Two)
+	sig2, err := o.SignContext(ctx, Two)
+	if err != nil {
+		return nil, err
+	}
 	x = new(big.Int).Exp(sig2, e, N)
 	pow2 = new(big.Int).Exp(Two, big.NewInt(513), N)
 	pow2.Mod(pow2.Add(pow2, Two), N)
 	if x.Cmp(pow2) != 0 {
-		panic("sig2 != (2^512 + 1)^d 2^d (mod N)")
+		return nil, errors.New("sig2 != (2^512 + 1)^d 2^d (mod N)")
 	}
 	// Sig(1)^{-1} * Sig(2) = 2^d (mod N)
 	inv := new(big.Int).ModInverse(sig1, N)
@@ -104,19 +146,22 @@ func main() {
 	twoToD.Mod(twoToD, N)
 	y := new(big.Int).Exp(twoToD, e, N)
 	if y.Cmp(Two) != 0 {
-		panic("(sig1)^{-1} * sig2 != 2^d (mod N)")
+		return nil, errors.New("(sig1)^{-1} * sig2 != 2^d (mod N)")
 	}
 	// Sig(m/2) = Sig(1) * (m/2)^d
 	m2 := new(big.Int).Div(chall, Two)
-	sig3 := o.Sign(m2)
+	sig3, err := o.SignContext(ctx, m2)
+	if err != nil {
+		return nil, err
+	}
 	prod := new(big.Int).Mul(inv, sig3)
 	prod.Mod(prod, N)
 	y.Exp(prod, e, N)
 	if y.Cmp(m2) != 0 {
-		panic("(sig1)^{-1} * sig3 != (m/2)^d (mod N)")
+		return nil, errors.New("(sig1)^{-1} * sig3 != (m/2)^d (mod N)")
 	}
 	// Sig(m/2) * 2^d = Sign(m)
 	final := new(big.Int).Mul(sig3, twoToD)
 	final.Mod(final, N)
-	fmt.Println(o.Vrfy(chall, final))
+	return final, nil
 }
@@ -0,0 +1,50 @@
+package plainrsa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"math/big"
+	"testing"
+
+	"github.com/gpdionisio/umcp_cryptography/oracles"
+	"github.com/gpdionisio/umcp_cryptography/week_07-rsa/server"
+)
+
+// forge checks the oracle and forges the signature of the challenge,
+// as cryptbreak rsa-forge does
+func forge(t *testing.T, o *RsaOracle, pub *rsa.PublicKey, chall *big.Int) *big.Int {
+	t.Helper()
+	ctx := context.Background()
+	// Check expects the refusal of the challenge (oracle.ErrOriginalMessage)
+	if err := o.Check(ctx, chall); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := o.Forge(ctx, pub.N, big.NewInt(int64(pub.E)), chall)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := o.VrfyContext(ctx, chall, sig)
+	if err != nil || !ok {
+		t.Fatalf("Vrfy(forged signature) = %v, %v", ok, err)
+	}
+	return sig
+}
+
+func TestForgeReplay(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := server.New(key)
+	chall := new(big.Int).SetBytes([]byte(server.CHALLENGE))
+
+	rec := &oracles.Recorder{Signer: s, Verifier: s}
+	sig := forge(t, NewRsaOracleFrom(rec, rec), s.PublicKey(), chall)
+
+	r := oracles.NewReplayer(rec.Exchanges())
+	replayed := forge(t, NewRsaOracleFrom(r, r), s.PublicKey(), chall)
+	if replayed.Cmp(sig) != 0 {
+		t.Errorf("replayed Forge = %x, recorded %x", replayed, sig)
+	}
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/rsa"
+	"errors"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gpdionisio/umcp_cryptography/week_07-rsa/oracle"
+)
+
+const (
+	MAX_MSG_LEN   = 504  //nolint
+	MAX_SIGMA_LEN = 1024 //nolint
+
+	// Challenge text, signatures on it are refused
+	CHALLENGE = "Crypto is hard --- even schemes that look complex can be broken" //nolint
+)
+
+// Server is a Go port of proj5's rsa_server.py: it signs messages with
+// J. Random Hacker's scheme [M = 0x00 m 0x00 m], sigma = M^d mod N,
+// and verifies (message, signature)-pairs
+type Server struct {
+	key      *rsa.PrivateKey
+	original *big.Int
+
+	mu    sync.Mutex
+	lns   []net.Listener
+	conns map[net.Conn]struct{}
+}
+
+// New creates a signing/verification server for the given private key.
+// The modulus must be at least 1024 bits long so that M < N.
+func New(key *rsa.PrivateKey) *Server {
+	return &Server{
+		key:      key,
+		original: new(big.Int).SetBytes([]byte(CHALLENGE)),
+		conns:    make(map[net.Conn]struct{}),
+	}
+}
+
+// PublicKey returns the public key (N, e) of the server
+func (s *Server) PublicKey() *rsa.PublicKey {
+	return &s.key.PublicKey
+}
+
+// transform maps m => (0..0m | 0..0m) where both halves are
+// MAX_MSG_LEN+8 bits long
+func transform(m *big.Int) *big.Int {
+	M := new(big.Int).Lsh(m, MAX_MSG_LEN+8)
+	return M.Or(M, m)
+}
+
+// sign returns the signature of m (which must be at most MAX_MSG_LEN bits)
+func (s *Server) sign(m *big.Int) *big.Int {
+	return new(big.Int).Exp(transform(m), s.key.D, s.key.N)
+}
+
+// verify checks whether sigma is a valid signature of m
+func (s *Server) verify(m, sigma *big.Int) bool {
+	e := big.NewInt(int64(s.key.E))
+	return transform(m).Cmp(new(big.Int).Exp(sigma, e, s.key.N)) == 0
+}
+
+// Signature signs in-process the message (a big-endian integer). As over
+// TCP, only the first MAX_MSG_LEN bits of its binary string are signed.
+// It implements oracles.SigningOracle.
+func (s *Server) Signature(ctx context.Context, mess []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m := truncate(new(big.Int).SetBytes(mess), MAX_MSG_LEN)
+	if m.Cmp(s.original) == 0 {
+		return nil, oracle.ErrOriginalMessage
+	}
+	return s.sign(m).Bytes(), nil
+}
+
+// Verify checks in-process the signature of the message (both big-endian
+// integers, truncated like over TCP). It implements oracles.VerifyOracle.
+func (s *Server) Verify(ctx context.Context, mess, sig []byte) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	m := truncate(new(big.Int).SetBytes(mess), MAX_MSG_LEN)
+	sigma := truncate(new(big.Int).SetBytes(sig), MAX_SIGMA_LEN)
+	return s.verify(m, sigma), nil
+}
+
+// ListenAndServe listens on the TCP addresses signAddr and vrfyAddr and
+// serves both oracles until one of the listeners fails
+func (s *Server) ListenAndServe(signAddr, vrfyAddr string) error {
+	signLn, err := net.Listen("tcp", signAddr)
+	if err != nil {
+		return err
+	}
+	vrfyLn, err := net.Listen("tcp", vrfyAddr)
+	if err != nil {
+		signLn.Close()
+		return err
+	}
+	errs := make(chan error, 2)
+	go func() { errs <- s.ServeSign(signLn) }()
+	go func() { errs <- s.ServeVrfy(vrfyLn) }()
+	err = <-errs
+	s.Close()
+	<-errs
+	return err
+}
+
+// ServeSign accepts connections on the listener and answers signing
+// requests < message || "X" > where message is a binary string.
+// Replies with the signature as a binary string, or with the error codes
+// NOT_BINARY_STR_ERR and ORIGINAL_MSG_ERR.
+func (s *Server) ServeSign(ln net.Listener) error {
+	return s.serve(ln, func(data string) string {
+		// Accept only the first MAX_MSG_LEN "bits"
+		m, ok := parseBinStr(data, MAX_MSG_LEN)
+		if !ok {
+			return strconv.Itoa(oracle.NOT_BINARY_STR_ERR)
+		}
+		if transform(m).Cmp(transform(s.original)) == 0 {
+			return strconv.Itoa(oracle.ORIGINAL_MSG_ERR)
+		}
+		return s.sign(m).Text(2)
+	})
+}
+
+// ServeVrfy accepts connections on the listener and answers verification
+// requests < message || ":" || signature || "X" > where message and
+// signature are binary strings. Replies "1" if the signature is valid,
+// "0" if not, or the error codes NOT_BINARY_STR_ERR and
+// MISSING_DELIMITER_ERR.
+func (s *Server) ServeVrfy(ln net.Listener) error {
+	return s.serve(ln, func(data string) string {
+		parts := strings.Split(data, ":")
+		if len(parts) != 2 {
+			return strconv.Itoa(oracle.MISSING_DELIMITER_ERR)
+		}
+		// Accept only the first MAX_MSG_LEN "bits" of msg
+		// and the first MAX_SIGMA_LEN "bits" of signature
+		m, ok := parseBinStr(parts[0], MAX_MSG_LEN)
+		if !ok {
+			return strconv.Itoa(oracle.NOT_BINARY_STR_ERR)
+		}
+		sigma, ok := parseBinStr(parts[1], MAX_SIGMA_LEN)
+		if !ok {
+			return strconv.Itoa(oracle.NOT_BINARY_STR_ERR)
+		}
+		if s.verify(m, sigma) {
+			return "1"
+		}
+		return "0"
+	})
+}
+
+// Close stops the listeners and drops all the clients
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	for _, ln := range s.lns {
+		if e := ln.Close(); e != nil && !errors.Is(e, net.ErrClosed) {
+			err = e
+		}
+	}
+	s.lns = nil
+	for conn := range s.conns {
+		conn.Close()
+		delete(s.conns, conn)
+	}
+	return err
+}
+
+// parseBinStr converts the first maxlen chars of a binary string
+func parseBinStr(str string, maxlen int) (*big.Int, bool) {
+	if len(str) > maxlen {
+		str = str[:maxlen]
+	}
+	return new(big.Int).SetString(str, 2)
+}
+
+// truncate keeps the first maxlen chars of the binary string of m, as
+// rsa_server.py does with the strings it receives
+func truncate(m *big.Int, maxlen int) *big.Int {
+	t, _ := parseBinStr(m.Text(2), maxlen)
+	return t
+}
+
+// serve accepts clients and, for each one, replies to every "X"-terminated
+// request until the client disconnects. As in rsa_server.py, replies are
+// not terminated.
+func (s *Server) serve(ln net.Listener, handle func(data string) string) error {
+	s.mu.Lock()
+	s.lns = append(s.lns, ln)
+	s.mu.Unlock()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		go func() {
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+			r := bufio.NewReader(conn)
+			for {
+				data, err := r.ReadString('X')
+				if err != nil {
+					return
+				}
+				resp := handle(data[:len(data)-1])
+				if _, err := conn.Write([]byte(resp)); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}